@@ -0,0 +1,45 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/etkecc/ansible-ssh/internal/ansible"
+	"github.com/etkecc/ansible-ssh/internal/config"
+	"github.com/etkecc/ansible-ssh/internal/history"
+	"github.com/etkecc/ansible-ssh/internal/logger"
+	"github.com/etkecc/ansible-ssh/internal/ssh"
+)
+
+const broadcastUsage = "usage: ansible-ssh broadcast PATTERN"
+
+// runBroadcast implements the `ansible-ssh broadcast PATTERN` subcommand,
+// it opens the ssh sessions to all matched hosts within the built-in terminal that sends the keystrokes to all of them
+func runBroadcast(cfg *config.Config, rawArgs, environ []string) {
+	flags := flag.NewFlagSet("broadcast", flag.ContinueOnError)
+	flags.SetOutput(os.Stderr)
+	flags.Usage = func() { fmt.Fprintln(os.Stderr, broadcastUsage) }
+	if err := flags.Parse(rawArgs); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		os.Exit(2)
+	}
+	if flags.NArg() != 1 {
+		logger.Fatal(broadcastUsage)
+	}
+
+	hosts := ansible.ListHosts(cfg, flags.Arg(0))
+	if len(hosts) == 0 {
+		logger.Fatal("no hosts matched ", flags.Arg(0))
+	}
+	hist := history.Load()
+	for _, host := range hosts {
+		recordHistory(hist, ansible.HostID(host))
+	}
+	if err := ssh.Broadcast(cfg.SSHCommand, hosts, environ); err != nil {
+		logger.Fatal("cannot run the broadcast terminal:", err)
+	}
+}
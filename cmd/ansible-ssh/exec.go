@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/etkecc/ansible-ssh/internal/ansible"
+	"github.com/etkecc/ansible-ssh/internal/config"
+	"github.com/etkecc/ansible-ssh/internal/logger"
+	"github.com/etkecc/ansible-ssh/internal/ssh"
+)
+
+const (
+	execUsage = "usage: ansible-ssh exec [--forks N] [--timeout DURATION] [--collect] PATTERN -- COMMAND [ARGS]..."
+	// defaultForks is the default max number of hosts to run the command on in parallel, same as ansible's
+	defaultForks = 5
+)
+
+// runExec implements the `ansible-ssh exec PATTERN -- COMMAND` subcommand,
+// it runs the non-interactive command on every matched host and prints the summary of exit codes and durations
+func runExec(cfg *config.Config, rawArgs, environ []string) {
+	sep := slices.Index(rawArgs, "--")
+	if sep == -1 || sep == len(rawArgs)-1 {
+		logger.Fatal(execUsage)
+	}
+	command := rawArgs[sep+1:]
+	rawArgs = rawArgs[:sep]
+
+	forksDefault := cfg.Exec.Forks
+	if forksDefault <= 0 {
+		forksDefault = defaultForks
+	}
+	flags := flag.NewFlagSet("exec", flag.ContinueOnError)
+	flags.SetOutput(os.Stderr)
+	flags.Usage = func() { fmt.Fprintln(os.Stderr, execUsage) }
+	forks := flags.Int("forks", forksDefault, "max number of hosts to run the command on in parallel")
+	timeout := flags.Duration("timeout", time.Duration(cfg.Exec.Timeout)*time.Second, "per-host timeout, e.g. 30s, 0 disables it")
+	collect := flags.Bool("collect", cfg.Exec.Collect, "print the output per host when the command finishes, instead of the lines prefixed with the host names")
+
+	// flags may go before and after the pattern
+	var patterns []string
+	for {
+		if err := flags.Parse(rawArgs); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return
+			}
+			os.Exit(2)
+		}
+		if flags.NArg() == 0 {
+			break
+		}
+		patterns = append(patterns, flags.Arg(0))
+		rawArgs = flags.Args()[1:]
+	}
+	if len(patterns) != 1 {
+		logger.Fatal(execUsage)
+	}
+
+	hosts := ansible.ListHosts(cfg, patterns[0])
+	if len(hosts) == 0 {
+		logger.Fatal("no hosts matched ", patterns[0])
+	}
+	opts := ssh.ExecOptions{Forks: *forks, Timeout: *timeout, Collect: *collect}
+	results := ssh.Exec(cfg.SSHCommand, hosts, command, opts, environ)
+	if dryRun.Enabled {
+		return
+	}
+
+	if !printExecSummary(os.Stdout, results) {
+		os.Exit(1)
+	}
+}
+
+// printExecSummary prints the table of exit codes and durations, returns false if any host failed
+func printExecSummary(out io.Writer, results []ssh.ExecResult) bool {
+	ok := true
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "\nHOST\tSTATUS\tEXIT\tDURATION")
+	for _, result := range results {
+		status, exitCode := "ok", strconv.Itoa(result.ExitCode)
+		switch {
+		case errors.Is(result.Err, ssh.ErrTimeout):
+			status, exitCode = "timeout", "-"
+		case result.Err != nil:
+			status, exitCode = "error: "+result.Err.Error(), "-"
+		case result.ExitCode != 0:
+			status = "failed"
+		}
+		if !result.OK() {
+			ok = false
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", result.Host.Name, status, exitCode, result.Duration.Round(time.Millisecond))
+	}
+	w.Flush()
+	return ok
+}
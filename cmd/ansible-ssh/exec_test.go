@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/etkecc/ansible-ssh/internal/ssh"
+	"github.com/etkecc/go-ansible"
+)
+
+func TestPrintExecSummary(t *testing.T) {
+	web01 := &ansible.Host{Name: "web01"}
+	web02 := &ansible.Host{Name: "web02"}
+	tests := []struct {
+		name     string
+		results  []ssh.ExecResult
+		ok       bool
+		expected []string // expected lines (whitespace-separated fields)
+	}{
+		{
+			name:     "all succeeded",
+			results:  []ssh.ExecResult{{Host: web01, Duration: 1500 * time.Millisecond}, {Host: web02, Duration: time.Second}},
+			ok:       true,
+			expected: []string{"HOST STATUS EXIT DURATION", "web01 ok 0 1.5s", "web02 ok 0 1s"},
+		},
+		{
+			name:     "exit code",
+			results:  []ssh.ExecResult{{Host: web01}, {Host: web02, ExitCode: 3, Duration: time.Second}},
+			ok:       false,
+			expected: []string{"web01 ok 0 0s", "web02 failed 3 1s"},
+		},
+		{
+			name:     "timeout",
+			results:  []ssh.ExecResult{{Host: web01, ExitCode: -1, Err: ssh.ErrTimeout, Duration: time.Second}},
+			ok:       false,
+			expected: []string{"web01 timeout - 1s"},
+		},
+		{
+			name:     "start error",
+			results:  []ssh.ExecResult{{Host: web01, ExitCode: -1, Err: errors.New("not found")}},
+			ok:       false,
+			expected: []string{"web01 error: not found - 0s"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if ok := printExecSummary(&out, test.results); ok != test.ok {
+				t.Errorf("expected %t, got %t", test.ok, ok)
+			}
+			lines := []string{}
+			for _, line := range strings.Split(out.String(), "\n") {
+				lines = append(lines, strings.Join(strings.Fields(line), " "))
+			}
+			for _, line := range test.expected {
+				if !slices.Contains(lines, line) {
+					t.Errorf("expected line %q within %q", line, out.String())
+				}
+			}
+		})
+	}
+}
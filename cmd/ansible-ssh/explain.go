@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/etkecc/ansible-ssh/internal/ansible"
+	"github.com/etkecc/ansible-ssh/internal/config"
+	"github.com/etkecc/ansible-ssh/internal/logger"
+	"github.com/etkecc/ansible-ssh/internal/shell"
+	"github.com/etkecc/ansible-ssh/internal/ssh"
+)
+
+// redacted replaces secret values (passwords) within the output
+const redacted = "<redacted>"
+
+// runExplain implements the `ansible-ssh explain HOST [ssh args]` subcommand,
+// it prints every resolved connection setting with its origin and the overridden values,
+// and the command line that would be executed (without executing it)
+func runExplain(cfg *config.Config, rawArgs []string) {
+	args := ssh.ParseArgs(rawArgs)
+	if args.Host == "" {
+		logger.Fatal("usage: ansible-ssh explain HOST [ssh args]")
+	}
+	explanation, err := ansible.Explain(cfg, args.Host)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	host := explanation.Host
+	fmt.Println("host:", host.Name)
+	fmt.Println("inventory:", host.Vars.String("inventory_file"))
+	fmt.Println()
+	for _, setting := range explanation.Settings {
+		if setting.Origin == nil {
+			continue
+		}
+		fmt.Printf("%s: %s\n", setting.Name, explainValue(setting, setting.Value))
+		fmt.Printf("    from %s\n", explainOrigin(setting, *setting.Origin))
+		for _, origin := range setting.Overrides {
+			fmt.Printf("    overrides %s\n", explainOrigin(setting, origin))
+		}
+	}
+	fmt.Println()
+	fmt.Println("command:")
+	argv, err := ssh.Command(cfg.SSHCommand, host, args, cfg.InventoryOnly)
+	if err != nil {
+		fmt.Println("    cannot be built:", err)
+		return
+	}
+	fmt.Println("   ", shell.Join(argv))
+}
+
+// explainValue returns the value to print, secrets are redacted
+func explainValue(setting ansible.Setting, value string) string {
+	if setting.Secret && value != "" {
+		return redacted
+	}
+	return value
+}
+
+// explainOrigin returns the human-readable origin, e.g. `ansible_user = "deploy" (/path/to/host_vars/web01.yml:3)`
+func explainOrigin(setting ansible.Setting, origin ansible.Origin) string {
+	if origin.Var == "" {
+		return origin.Source
+	}
+	value := explainValue(setting, origin.Value)
+	if !setting.Secret && origin.Value != setting.Value && origin == *setting.Origin {
+		value += " (rendered as " + setting.Value + ")"
+	}
+	return fmt.Sprintf("%s = %s (%s)", origin.Var, value, origin.Source)
+}
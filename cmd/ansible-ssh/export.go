@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/etkecc/ansible-ssh/internal/ansible"
+	"github.com/etkecc/ansible-ssh/internal/config"
+	"github.com/etkecc/ansible-ssh/internal/logger"
+	"github.com/etkecc/ansible-ssh/internal/ssh"
+)
+
+const (
+	exportUsage = "usage: ansible-ssh export ssh-config [--include-file] [PATTERN]"
+	// includeFile is the managed ssh config file, relative to the home dir
+	includeFile = ".ssh/config.d/ansible-ssh"
+	// exportHeader is the header of the exported ssh config
+	exportHeader = "# generated by ansible-ssh export ssh-config, do not edit manually\n"
+)
+
+// runExport implements the `ansible-ssh export ssh-config [PATTERN]` subcommand,
+// it prints the inventory hosts as the OpenSSH client config, or writes them into the managed include file
+func runExport(cfg *config.Config, rawArgs []string) {
+	if len(rawArgs) == 0 || rawArgs[0] != "ssh-config" {
+		logger.Fatal(exportUsage)
+	}
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	flags.SetOutput(os.Stderr)
+	flags.Usage = func() { fmt.Fprintln(os.Stderr, exportUsage) }
+	include := flags.Bool("include-file", false, "write the config into ~/"+includeFile+" (only if changed) instead of stdout")
+
+	// flags may go before and after the pattern
+	var patterns []string
+	rawArgs = rawArgs[1:]
+	for {
+		if err := flags.Parse(rawArgs); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return
+			}
+			os.Exit(2)
+		}
+		if flags.NArg() == 0 {
+			break
+		}
+		patterns = append(patterns, flags.Arg(0))
+		rawArgs = flags.Args()[1:]
+	}
+	if len(patterns) > 1 {
+		logger.Fatal(exportUsage)
+	}
+	var pattern string
+	if len(patterns) == 1 {
+		pattern = patterns[0]
+	}
+
+	data := exportHeader + ssh.Config(ansible.ListHosts(cfg, pattern))
+	if !*include {
+		fmt.Print(data)
+		return
+	}
+	if err := writeIncludeFile([]byte(data)); err != nil {
+		logger.Fatal("cannot write the ssh config:", err)
+	}
+}
+
+// writeIncludeFile writes the config into the managed include file, if the contents have changed
+func writeIncludeFile(data []byte) error {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(home, includeFile)
+	if current, err := os.ReadFile(path); err == nil && bytes.Equal(current, data) {
+		logger.Println(path, "is up to date")
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	logger.Println(path, "has been updated")
+
+	if sshConfig, err := os.ReadFile(filepath.Join(home, ".ssh", "config")); err != nil || !bytes.Contains(sshConfig, []byte("config.d/")) {
+		logger.Println("add `Include config.d/*` to the top of ~/.ssh/config to use it")
+	}
+	return nil
+}
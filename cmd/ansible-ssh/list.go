@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/etkecc/ansible-ssh/internal/ansible"
+	"github.com/etkecc/ansible-ssh/internal/config"
+	"github.com/etkecc/ansible-ssh/internal/logger"
+)
+
+const listUsage = "usage: ansible-ssh list [--format table|json|csv|names] [--group GROUP] [--var KEY=VALUE]... [PATTERN]"
+
+// listHost is a host within the `ansible-ssh list` output
+type listHost struct {
+	Name          string   `json:"name"`
+	Address       string   `json:"address"`
+	Port          int      `json:"port"`
+	User          string   `json:"user"`
+	Groups        []string `json:"groups"`
+	PrivateKeys   []string `json:"private_keys"`
+	InventoryFile string   `json:"inventory_file"`
+	TODOs         bool     `json:"todos"`
+}
+
+// varFilters is the repeatable --var KEY=VALUE flag
+type varFilters []string
+
+// String implements flag.Value
+func (f *varFilters) String() string {
+	return strings.Join(*f, ", ")
+}
+
+// Set implements flag.Value
+func (f *varFilters) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// runList implements the `ansible-ssh list [PATTERN]` subcommand,
+// it prints the hosts that ansible-ssh can resolve, in the table, JSON, CSV or names-only format
+func runList(cfg *config.Config, rawArgs []string) {
+	var vars varFilters
+	flags := flag.NewFlagSet("list", flag.ContinueOnError)
+	flags.SetOutput(os.Stderr)
+	flags.Usage = func() { fmt.Fprintln(os.Stderr, listUsage) }
+	format := flags.String("format", "table", "output format: table, json, csv or names")
+	group := flags.String("group", "", "list only the hosts of the group")
+	flags.Var(&vars, "var", "list only the hosts with the var value, KEY=VALUE (or KEY to check that the var is set), may be repeated")
+
+	// flags may go before and after the pattern
+	var patterns []string
+	for {
+		if err := flags.Parse(rawArgs); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return
+			}
+			os.Exit(2)
+		}
+		if flags.NArg() == 0 {
+			break
+		}
+		patterns = append(patterns, flags.Arg(0))
+		rawArgs = flags.Args()[1:]
+	}
+	if len(patterns) > 1 {
+		logger.Fatal(listUsage)
+	}
+	if !slices.Contains([]string{"table", "json", "csv", "names"}, *format) {
+		logger.Fatal("unsupported format ", *format, ", ", listUsage)
+	}
+
+	var pattern string
+	if len(patterns) == 1 {
+		pattern = patterns[0]
+	}
+	hosts := []listHost{}
+	for _, host := range ansible.ListHosts(cfg, pattern) {
+		if *group != "" && !slices.Contains(host.Groups, *group) {
+			continue
+		}
+		if !matchVars(host.Vars, vars) {
+			continue
+		}
+		hosts = append(hosts, listHost{
+			Name:          host.Name,
+			Address:       host.Host,
+			Port:          host.Port,
+			User:          host.User,
+			Groups:        ansible.GroupNames(host),
+			PrivateKeys:   append([]string{}, host.PrivateKeys...),
+			InventoryFile: host.Vars.String("inventory_file"),
+			TODOs:         host.HasTODOs(),
+		})
+	}
+
+	var err error
+	switch *format {
+	case "json":
+		err = printListJSON(hosts)
+	case "csv":
+		err = printListCSV(hosts)
+	case "names":
+		for _, host := range hosts {
+			fmt.Println(host.Name)
+		}
+	default:
+		err = printListTable(hosts)
+	}
+	if err != nil {
+		logger.Fatal("cannot print the hosts list:", err)
+	}
+}
+
+// matchVars returns true if the host vars match all filters: KEY=VALUE filters compare the var value,
+// KEY filters check that the var is set
+func matchVars(vars map[string]any, filters []string) bool {
+	for _, filter := range filters {
+		key, want, withValue := strings.Cut(filter, "=")
+		value, ok := vars[key]
+		if !ok || value == nil {
+			return false
+		}
+		if withValue && fmt.Sprint(value) != want {
+			return false
+		}
+	}
+	return true
+}
+
+// printListJSON prints the hosts as JSON array
+func printListJSON(hosts []listHost) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(hosts)
+}
+
+// printListCSV prints the hosts as CSV with header, groups and private keys are space-separated
+func printListCSV(hosts []listHost) error {
+	w := csv.NewWriter(os.Stdout)
+	w.Write([]string{"name", "address", "port", "user", "groups", "private_keys", "inventory_file", "todos"}) //nolint:errcheck // checked by w.Error()
+	for _, host := range hosts {
+		w.Write([]string{ //nolint:errcheck // checked by w.Error()
+			host.Name,
+			host.Address,
+			strconv.Itoa(host.Port),
+			host.User,
+			strings.Join(host.Groups, " "),
+			strings.Join(host.PrivateKeys, " "),
+			host.InventoryFile,
+			strconv.FormatBool(host.TODOs),
+		})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// printListTable prints the hosts as human-readable table
+func printListTable(hosts []listHost) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tADDRESS\tPORT\tUSER\tGROUPS\tKEYS\tINVENTORY\tTODOS")
+	for _, host := range hosts {
+		port := ""
+		if host.Port != 0 {
+			port = strconv.Itoa(host.Port)
+		}
+		todos := ""
+		if host.TODOs {
+			todos = "yes"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			host.Name, host.Address, port, host.User,
+			strings.Join(host.Groups, ","), strings.Join(host.PrivateKeys, ","), host.InventoryFile, todos)
+	}
+	return w.Flush()
+}
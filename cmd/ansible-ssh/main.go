@@ -2,21 +2,40 @@ package main
 
 import (
 	"os"
+	"sort"
+	"strings"
 
 	"github.com/adrg/xdg"
 
 	"github.com/etkecc/ansible-ssh/internal/ansible"
 	"github.com/etkecc/ansible-ssh/internal/config"
+	"github.com/etkecc/ansible-ssh/internal/history"
 	"github.com/etkecc/ansible-ssh/internal/logger"
 	"github.com/etkecc/ansible-ssh/internal/ssh"
 )
 
+// dryRun is the dry-run mode, set by the --dry-run, --print and --show-passwords flags
+var dryRun ssh.DryRun
+
 func main() {
-	if len(os.Args) < 2 {
-		logger.Println("you need to provide at least host name")
+	if ssh.IsAskpass(os.Args[0]) {
+		if err := ssh.RunAskpass(os.Args[1:]); err != nil {
+			os.Exit(1)
+		}
 		return
 	}
 
+	args := os.Args[1:]
+	tool, isTransfer := transferTool(os.Args[0])
+	if !isTransfer { // the flags belong to the transfer tool, e.g. rsync --dry-run
+		dryRun, args = parseDryRunFlags(args)
+	}
+	if dryRun.Enabled || (len(args) > 0 && (args[0] == "proxy" || args[0] == "list" || args[0] == "export" || args[0] == "exec")) {
+		// stdout is the data channel in the proxy mode, and the output of the list, export and exec subcommands and the dry-run mode
+		logger.SetOutput(os.Stderr)
+	}
+	ssh.SetDryRun(dryRun)
+
 	path, err := xdg.SearchConfigFile("ansible-ssh.yml")
 	if err != nil {
 		logger.Fatal("cannot find the ansible-ssh.yml config file:", err)
@@ -26,18 +45,98 @@ func main() {
 		logger.Fatal("cannot read the ansible-ssh.yml config file:", err)
 	}
 	logger.Configure(cfg.Debug)
+	ssh.SetPrintPasswords(cfg.PrintPasswords)
 
 	environ := make([]string, 0)
 	for k, v := range cfg.Environ {
 		environ = append(environ, k+"="+v)
 	}
+	sort.Strings(environ)
 
-	host := ansible.GetHost(cfg.Path, os.Args[1], &cfg.Defaults)
+	if isTransfer {
+		runTransfer(cfg, tool, args, environ)
+		return
+	}
+	if len(args) == 0 {
+		runPicker(cfg, environ)
+		return
+	}
+
+	switch args[0] {
+	case "proxy":
+		runProxy(cfg, args[1:], environ)
+	case "explain":
+		runExplain(cfg, args[1:])
+	case "exec":
+		runExec(cfg, args[1:], environ)
+	case "export":
+		runExport(cfg, args[1:])
+	case "broadcast":
+		runBroadcast(cfg, args[1:], environ)
+	case "tmux":
+		runTmux(cfg, args[1:], environ)
+	case "scp", "sftp", "rsync":
+		runTransfer(cfg, args[0], args[1:], environ)
+	case "list":
+		runList(cfg, args[1:])
+	case "where":
+		runWhere(cfg, args[1:])
+	default:
+		runSSH(cfg, args, environ)
+	}
+}
+
+func runSSH(cfg *config.Config, rawArgs, environ []string) {
+	args := ssh.ParseArgs(rawArgs)
+	if args.Host == "" {
+		logger.Debug("destination not found in the arguments")
+		ssh.Run(cfg.SSHCommand, nil, args, cfg.InventoryOnly, environ)
+		return
+	}
+
+	host := ansible.GetHost(cfg, args.Host)
 	if host == nil {
-		ssh.Run(cfg.SSHCommand, nil, cfg.InventoryOnly, environ)
+		ssh.Run(cfg.SSHCommand, nil, args, cfg.InventoryOnly, environ)
 		return
 	}
 
 	logger.Debug("host", host.Name, "has been found, starting ssh")
-	ssh.Run(cfg.SSHCommand, host, cfg.InventoryOnly, environ)
+	recordHistory(history.Load(), ansible.HostID(host))
+	ssh.Run(cfg.SSHCommand, host, args, cfg.InventoryOnly, environ)
+}
+
+// parseDryRunFlags extracts the --dry-run, --print[=text|json] and --show-passwords flags from the arguments,
+// they are recognized anywhere before the first "--", which is kept, so the remote command after it may use the same flags.
+// The arguments of the scp, sftp and rsync subcommands are kept as is, as these tools have their own flags (e.g. rsync --dry-run)
+func parseDryRunFlags(args []string) (ssh.DryRun, []string) {
+	var dryRun ssh.DryRun
+	rest := make([]string, 0, len(args))
+	for i, arg := range args {
+		if arg == "--" || (len(rest) == 0 && ssh.IsTransferTool(arg)) {
+			return dryRun, append(rest, args[i:]...)
+		}
+		switch flag, value, _ := strings.Cut(arg, "="); flag {
+		case "--dry-run", "--print":
+			dryRun.Enabled = true
+			switch value {
+			case "", "text":
+			case "json":
+				dryRun.JSON = true
+			default:
+				logger.Fatal("unsupported print format ", value, ", use text or json")
+			}
+		case "--show-passwords":
+			dryRun.ShowPasswords = true
+		default:
+			rest = append(rest, arg)
+		}
+	}
+	return dryRun, rest
+}
+
+// recordHistory records the connection to the host within the history, dry runs are not recorded
+func recordHistory(hist *history.History, id string) {
+	if !dryRun.Enabled {
+		hist.Record(id)
+	}
 }
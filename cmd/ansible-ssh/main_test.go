@@ -0,0 +1,38 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/etkecc/ansible-ssh/internal/ssh"
+)
+
+func TestParseDryRunFlags(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		expected ssh.DryRun
+		rest     []string
+	}{
+		{"none", []string{"web01", "uptime"}, ssh.DryRun{}, []string{"web01", "uptime"}},
+		{"leading", []string{"--dry-run", "web01"}, ssh.DryRun{Enabled: true}, []string{"web01"}},
+		{"print json", []string{"--print=json", "--show-passwords", "web01"}, ssh.DryRun{Enabled: true, JSON: true, ShowPasswords: true}, []string{"web01"}},
+		{"print text", []string{"--print=text", "web01"}, ssh.DryRun{Enabled: true}, []string{"web01"}},
+		{"after the host", []string{"web01", "-t", "--print", "uptime"}, ssh.DryRun{Enabled: true}, []string{"web01", "-t", "uptime"}},
+		{"subcommand", []string{"exec", "web", "--dry-run", "--", "uptime"}, ssh.DryRun{Enabled: true}, []string{"exec", "web", "--", "uptime"}},
+		{"transfer subcommand", []string{"--dry-run", "rsync", "--dry-run", "-av", "src", "web01:dst"}, ssh.DryRun{Enabled: true}, []string{"rsync", "--dry-run", "-av", "src", "web01:dst"}},
+		{"transfer subcommand without flags", []string{"scp", "--print", "web01:file", "."}, ssh.DryRun{}, []string{"scp", "--print", "web01:file", "."}},
+		{"after --", []string{"web01", "--", "apt-get", "--dry-run", "--show-passwords"}, ssh.DryRun{}, []string{"web01", "--", "apt-get", "--dry-run", "--show-passwords"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dryRun, rest := parseDryRunFlags(test.args)
+			if dryRun != test.expected {
+				t.Errorf("expected %+v, got %+v", test.expected, dryRun)
+			}
+			if !slices.Equal(rest, test.rest) {
+				t.Errorf("expected args %q, got %q", test.rest, rest)
+			}
+		})
+	}
+}
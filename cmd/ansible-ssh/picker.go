@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/etkecc/ansible-ssh/internal/ansible"
+	"github.com/etkecc/ansible-ssh/internal/config"
+	"github.com/etkecc/ansible-ssh/internal/history"
+	"github.com/etkecc/ansible-ssh/internal/logger"
+	"github.com/etkecc/ansible-ssh/internal/picker"
+	"github.com/etkecc/ansible-ssh/internal/prompt"
+	"github.com/etkecc/ansible-ssh/internal/ssh"
+)
+
+// runPicker implements `ansible-ssh` without arguments: the full-screen picker over all inventory hosts,
+// ranked by frecency of the past connections
+func runPicker(cfg *config.Config, environ []string) {
+	if !prompt.IsInteractive() {
+		logger.Println("you need to provide at least host name")
+		return
+	}
+	hosts := ansible.AllHosts(cfg)
+	if len(hosts) == 0 {
+		logger.Println("no hosts found in the inventory, you need to provide at least host name")
+		return
+	}
+
+	hist := history.Load()
+	items := make([]picker.Item, 0, len(hosts))
+	for _, host := range hosts {
+		items = append(items, picker.Item{
+			Name:    host.Name,
+			Group:   host.Group,
+			Address: host.Host,
+			User:    host.User,
+			Port:    host.Port,
+			Score:   hist.Score(ansible.HostID(host)),
+		})
+	}
+
+	idx, err := picker.Pick(items)
+	if errors.Is(err, errors.ErrUnsupported) {
+		idx, err = chooseFallback(items)
+	}
+	if err != nil {
+		if errors.Is(err, picker.ErrCanceled) || errors.Is(err, prompt.ErrCanceled) {
+			return
+		}
+		logger.Fatal("cannot pick the host:", err)
+	}
+
+	host := hosts[idx]
+	logger.Debug("host", host.Name, "has been picked, starting ssh")
+	recordHistory(hist, ansible.HostID(host))
+	ssh.Run(cfg.SSHCommand, host, ssh.ParseArgs([]string{host.Name}), cfg.InventoryOnly, environ)
+}
+
+// chooseFallback asks the user to pick the host using the numbered list, used when the full-screen picker is not supported
+func chooseFallback(items []picker.Item) (int, error) {
+	order := make([]int, len(items))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		if items[order[a]].Score != items[order[b]].Score {
+			return items[order[a]].Score > items[order[b]].Score
+		}
+		return items[order[a]].Name < items[order[b]].Name
+	})
+
+	labels := make([]string, 0, len(items))
+	for _, i := range order {
+		labels = append(labels, items[i].Name+" ("+items[i].Address+")")
+	}
+	idx, err := prompt.Choose("pick the host:", labels)
+	if err != nil {
+		return -1, err
+	}
+	return order[idx], nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net"
+	"os"
+	"strconv"
+
+	"github.com/etkecc/ansible-ssh/internal/ansible"
+	"github.com/etkecc/ansible-ssh/internal/config"
+	"github.com/etkecc/ansible-ssh/internal/logger"
+	"github.com/etkecc/ansible-ssh/internal/ssh"
+)
+
+// runProxy implements the `ansible-ssh proxy HOST [PORT]` subcommand,
+// intended to be used as `ProxyCommand ansible-ssh proxy %n [%p]` in the ~/.ssh/config
+func runProxy(cfg *config.Config, rawArgs, environ []string) {
+	if len(rawArgs) == 0 {
+		logger.Fatal("usage: ansible-ssh proxy HOST [PORT]")
+	}
+	name, port := rawArgs[0], 0
+	host := ansible.GetHost(cfg, name)
+	if host != nil {
+		name, port = host.Host, ssh.ConfiguredPort(host)
+	}
+	address := proxyAddress(name, port, rawArgs[1:])
+	switch {
+	case host != nil:
+		logger.Debug("host", host.Name, "has been found, proxying to", address)
+	case cfg.InventoryOnly:
+		logger.Fatal("host not found within inventory")
+	}
+
+	if jumpArgs := ssh.ProxyJumpArgs(host, address); jumpArgs != nil {
+		logger.Debug("proxying through the jump host", jumpArgs.Host)
+		ssh.Run(cfg.SSHCommand, ansible.GetHost(cfg, jumpArgs.Host), jumpArgs, false, environ)
+		return
+	}
+
+	if err := ssh.Proxy(address, os.Stdin, os.Stdout); err != nil {
+		logger.Fatal("proxy failed:", err)
+	}
+}
+
+// proxyAddress returns the host:port address to connect to, the port is the inventory port, or the PORT argument, or the default 22.
+// The inventory port wins, because ssh always passes %p (22 if the port is not set)
+func proxyAddress(name string, inventoryPort int, portArg []string) string {
+	port := "22"
+	if len(portArg) > 0 {
+		port = portArg[0]
+	}
+	if inventoryPort != 0 {
+		port = strconv.Itoa(inventoryPort)
+	}
+	return net.JoinHostPort(name, port)
+}
@@ -0,0 +1,27 @@
+package main
+
+import "testing"
+
+func TestProxyAddress(t *testing.T) {
+	tests := []struct {
+		name          string
+		host          string
+		inventoryPort int
+		portArg       []string
+		expected      string
+	}{
+		{"default port", "10.0.0.1", 0, nil, "10.0.0.1:22"},
+		{"inventory port", "10.0.0.1", 2222, nil, "10.0.0.1:2222"},
+		{"explicit port", "10.0.0.1", 0, []string{"2200"}, "10.0.0.1:2200"},
+		{"inventory port wins", "10.0.0.1", 2222, []string{"2200"}, "10.0.0.1:2222"},
+		{"inventory port wins over %p default", "10.0.0.1", 2222, []string{"22"}, "10.0.0.1:2222"},
+		{"ipv6", "::1", 2222, nil, "[::1]:2222"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if address := proxyAddress(test.host, test.inventoryPort, test.portArg); address != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, address)
+			}
+		})
+	}
+}
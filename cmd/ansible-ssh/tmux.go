@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/etkecc/ansible-ssh/internal/ansible"
+	"github.com/etkecc/ansible-ssh/internal/config"
+	"github.com/etkecc/ansible-ssh/internal/history"
+	"github.com/etkecc/ansible-ssh/internal/logger"
+	"github.com/etkecc/ansible-ssh/internal/ssh"
+)
+
+const (
+	tmuxUsage = "usage: ansible-ssh tmux [--sync] [--windows] [--layout LAYOUT] [--session NAME] PATTERN"
+	// defaultTmuxLayout is the default layout of the panes
+	defaultTmuxLayout = "tiled"
+)
+
+// runTmux implements the `ansible-ssh tmux PATTERN` subcommand,
+// it opens the ssh sessions to all matched hosts within one tmux session
+func runTmux(cfg *config.Config, rawArgs, environ []string) {
+	layoutDefault := cfg.Tmux.Layout
+	if layoutDefault == "" {
+		layoutDefault = defaultTmuxLayout
+	}
+	flags := flag.NewFlagSet("tmux", flag.ContinueOnError)
+	flags.SetOutput(os.Stderr)
+	flags.Usage = func() { fmt.Fprintln(os.Stderr, tmuxUsage) }
+	sync := flags.Bool("sync", cfg.Tmux.Sync, "enable synchronize-panes, so the input goes to all panes")
+	windows := flags.Bool("windows", cfg.Tmux.Windows, "one window per host instead of one pane per host")
+	layout := flags.String("layout", layoutDefault, "layout of the panes, e.g. tiled, even-horizontal or even-vertical")
+	session := flags.String("session", cfg.Tmux.Session, "session name (default ansible-ssh-PATTERN)")
+
+	// flags may go before and after the pattern
+	var patterns []string
+	for {
+		if err := flags.Parse(rawArgs); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return
+			}
+			os.Exit(2)
+		}
+		if flags.NArg() == 0 {
+			break
+		}
+		patterns = append(patterns, flags.Arg(0))
+		rawArgs = flags.Args()[1:]
+	}
+	if len(patterns) != 1 {
+		logger.Fatal(tmuxUsage)
+	}
+	if *session == "" {
+		*session = "ansible-ssh-" + patterns[0]
+	}
+
+	hosts := ansible.ListHosts(cfg, patterns[0])
+	if len(hosts) == 0 {
+		logger.Fatal("no hosts matched ", patterns[0])
+	}
+	hist := history.Load()
+	for _, host := range hosts {
+		recordHistory(hist, ansible.HostID(host))
+	}
+	opts := ssh.TmuxOptions{Session: *session, Layout: *layout, Windows: *windows, Sync: *sync}
+	ssh.Tmux(cfg.SSHCommand, hosts, opts, environ)
+}
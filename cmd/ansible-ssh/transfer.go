@@ -0,0 +1,29 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/etkecc/ansible-ssh/internal/ansible"
+	"github.com/etkecc/ansible-ssh/internal/config"
+	"github.com/etkecc/ansible-ssh/internal/ssh"
+)
+
+// programPrefix is the prefix of the symlinks to the ansible-ssh binary that pick the file transfer tool, e.g. ansible-scp
+const programPrefix = "ansible-"
+
+// transferTool returns the file transfer tool picked by the program name (argv[0]), e.g. scp for ansible-scp
+func transferTool(program string) (string, bool) {
+	name := strings.TrimSuffix(filepath.Base(program), ".exe")
+	tool, ok := strings.CutPrefix(name, programPrefix)
+	if !ok || !ssh.IsTransferTool(tool) {
+		return "", false
+	}
+	return tool, true
+}
+
+// runTransfer implements the scp, sftp and rsync front-ends (`ansible-ssh scp ...` or `ansible-scp ...`),
+// that rewrite the host:path operands using the inventory
+func runTransfer(cfg *config.Config, tool string, rawArgs, environ []string) {
+	ssh.RunTransfer(cfg.SSHCommand, tool, rawArgs, ansible.Resolver(cfg), cfg.InventoryOnly, environ)
+}
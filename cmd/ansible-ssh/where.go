@@ -0,0 +1,24 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/etkecc/ansible-ssh/internal/ansible"
+	"github.com/etkecc/ansible-ssh/internal/config"
+	"github.com/etkecc/ansible-ssh/internal/logger"
+)
+
+// runWhere implements the `ansible-ssh where HOST` subcommand,
+// it prints the inventory files that define the host
+func runWhere(cfg *config.Config, rawArgs []string) {
+	if len(rawArgs) != 1 {
+		logger.Fatal("usage: ansible-ssh where HOST")
+	}
+	hosts := ansible.Where(cfg, rawArgs[0])
+	if len(hosts) == 0 {
+		logger.Fatal("host ", rawArgs[0], " not found")
+	}
+	for _, host := range hosts {
+		fmt.Println(host.Vars.String("inventory_file"))
+	}
+}
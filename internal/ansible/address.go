@@ -0,0 +1,141 @@
+package ansible
+
+import (
+	"context"
+	"net"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/etkecc/ansible-ssh/internal/logger"
+	"github.com/etkecc/go-ansible"
+)
+
+// addressLookupTimeout is the timeout of the DNS lookup of the address that is not found within the raw vars
+const addressLookupTimeout = 2 * time.Second
+
+var (
+	// defaultFQNs are the matrix server FQN keys that have default values (e.g. matrix.example.com) even if not defined in vars
+	defaultFQNs = []string{"matrix", "element"}
+	// nameAddress matches the domain name (labels of letters, digits and dashes, the alphabetic TLD) or MXID (@user:domain)
+	nameAddress = regexp.MustCompile(`(?i)^(@[^@:\s]+:)?([a-z0-9]([a-z0-9-]*[a-z0-9])?\.)+[a-z]{2,}$`)
+)
+
+// findHostsByAddress returns the hosts that have the address within the loaded sources: the ansible_host value,
+// an IP address within the host vars, the matrix domain, server FQNs (e.g. matrix.example.com) or admin MXID.
+// The raw (not rendered, not decrypted) vars are matched first, and only the matched hosts are resolved.
+// All hosts are resolved only if nothing matched, and the address does not resolve in DNS (so ssh cannot connect to it anyway)
+func findHostsByAddress(invs *inventories, sources []source, address string) []*ansible.Host {
+	if !isAddress(address) {
+		return nil
+	}
+	if hosts := scanAddress(invs, sources, address, true); len(hosts) > 0 || resolvable(address) {
+		return hosts
+	}
+	logger.Debug("address", address, "does not resolve, looking for it within the rendered vars of all hosts")
+	return scanAddress(invs, sources, address, false)
+}
+
+// scanAddress returns the hosts that have the address, if rawFirst is set, only the hosts that have the address
+// within the raw vars are resolved
+func scanAddress(invs *inventories, sources []source, address string, rawFirst bool) []*ansible.Host {
+	hosts := []*ansible.Host{}
+	seen := map[string]bool{}
+	for _, src := range sources {
+		l := invs.load(src)
+		if l == nil {
+			continue
+		}
+		names, _ := l.match("") //nolint:errcheck // the empty pattern cannot fail
+		if rawFirst {
+			names = slices.DeleteFunc(names, func(name string) bool { return !hasAddress(l.rawHost(l.inv.Hosts[name]), address) })
+		}
+		for _, host := range l.resolve(names) {
+			if id := HostID(host); !seen[id] && hasAddress(host, address) {
+				seen[id] = true
+				hosts = append(hosts, host)
+			}
+		}
+	}
+	return hosts
+}
+
+// isAddress returns true if the input looks like an address (IP address, domain or MXID), and not like a host name, pattern or user@host
+func isAddress(input string) bool {
+	return net.ParseIP(input) != nil || nameAddress.MatchString(input)
+}
+
+// resolvable returns true if the address is an IP address or the domain (of the MXID) resolves in DNS
+func resolvable(address string) bool {
+	if net.ParseIP(address) != nil {
+		return true
+	}
+	if _, domain, ok := strings.Cut(address, ":"); ok {
+		address = domain
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), addressLookupTimeout)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupHost(ctx, address)
+	return err == nil && len(addrs) > 0
+}
+
+// hasAddress returns true if the host has the address
+func hasAddress(host *ansible.Host, address string) bool {
+	for _, candidate := range hostAddresses(host) {
+		if strings.EqualFold(candidate, address) {
+			return true
+		}
+	}
+	return false
+}
+
+// hostAddresses returns all known addresses of the host
+func hostAddresses(host *ansible.Host) []string {
+	addresses := []string{host.Host}
+	keys := make([]string, 0, len(host.Vars))
+	for key := range host.Vars {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		addresses = append(addresses, ipAddresses(host.Vars[key])...)
+	}
+
+	base, domain := host.Vars.Domain()
+	if domain == "" {
+		return addresses
+	}
+	addresses = append(addresses, base, domain)
+	fqns := append([]string{}, defaultFQNs...)
+	for _, key := range keys {
+		if name, ok := strings.CutPrefix(key, "matrix_server_fqn_"); ok {
+			fqns = append(fqns, name)
+		}
+	}
+	for _, name := range fqns {
+		addresses = append(addresses, host.Vars.FQN(name))
+	}
+	if admin := host.Vars.Admin(); admin != "" {
+		addresses = append(addresses, admin, strings.TrimPrefix(admin, "@"))
+	}
+	return addresses
+}
+
+// ipAddresses returns the IP addresses within the var value (strings and lists of strings)
+func ipAddresses(value any) []string {
+	switch v := value.(type) {
+	case string:
+		if net.ParseIP(v) != nil {
+			return []string{v}
+		}
+	case []any:
+		addresses := []string{}
+		for _, item := range v {
+			addresses = append(addresses, ipAddresses(item)...)
+		}
+		return addresses
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package ansible
+
+import (
+	"slices"
+	"sort"
+	"testing"
+
+	"github.com/etkecc/ansible-ssh/internal/config"
+)
+
+func TestIsAddress(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"203.0.113.7", true},
+		{"2001:db8::1", true},
+		{"matrix.example.com", true},
+		{"@admin:example.com", true},
+		{"web01", false},
+		{"git@gitlab.example.org", false},
+		{"host.example.com:22", false},
+		{"web*.example.com", false},
+		{"db:&prod", false},
+		{"./hosts.yml", false},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			if result := isAddress(test.input); result != test.expected {
+				t.Errorf("expected %t, got %t", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestFindHostsByAddress(t *testing.T) {
+	t.Setenv("ANSIBLE_INVENTORY", "")
+	tests := []struct {
+		name     string
+		address  string
+		expected []string
+		resolved []string // the hosts that have been resolved (rendered and decrypted) during the lookup
+	}{
+		{"ansible_host", "203.0.113.7", []string{"web01"}, []string{"web01"}},
+		{"IP within the vars", "198.51.100.2", []string{"web02"}, []string{"web02"}},
+		{"matrix domain", "matrix.example.com", []string{"matrix01"}, []string{"matrix01"}},
+		{"unknown IP", "203.0.113.99", []string{}, []string{}},
+		{"not an address", "git@gitlab.example.org", []string{}, []string{}},
+		{"unresolvable name", "nothing.invalid", []string{}, []string{"matrix01", "web01", "web02"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeFiles(t, root, map[string]string{
+				"hosts":                  "web01 ansible_host=203.0.113.7\nweb02 ansible_host=\"{{ server_ip }}\"\nmatrix01\n",
+				"host_vars/web02.yml":    "server_ip: 198.51.100.2\n",
+				"host_vars/matrix01.yml": "matrix_domain: example.com\nansible_become_password: !vault |\n  $ANSIBLE_VAULT;1.1;AES256\n  3030\n",
+			})
+			invs := newInventories(&config.Config{})
+			src := source{root: root, path: "hosts"}
+
+			names := []string{}
+			for _, host := range findHostsByAddress(invs, []source{src}, test.address) {
+				names = append(names, host.Name)
+			}
+			if !slices.Equal(names, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, names)
+			}
+
+			resolved := []string{}
+			if l := invs.loaded[src]; l != nil {
+				for name := range l.resolved {
+					resolved = append(resolved, name)
+				}
+			}
+			sort.Strings(resolved)
+			if !slices.Equal(resolved, test.resolved) {
+				t.Errorf("expected the resolved hosts %v, got %v", test.resolved, resolved)
+			}
+		})
+	}
+}
@@ -1,50 +1,162 @@
 package ansible
 
 import (
+	"fmt"
+	"slices"
+	"sort"
 	"strings"
 
 	"github.com/etkecc/ansible-ssh/internal/config"
 	"github.com/etkecc/ansible-ssh/internal/logger"
+	"github.com/etkecc/ansible-ssh/internal/prompt"
 	"github.com/etkecc/go-ansible"
 )
 
-// inventoryPrefixWorkaround is a workaround for ssh key path defined in the inventory/host_vars/DOMAIN/vars.yml file
-// at this moment, we do not have a way to parse it properly, so we just assume that the key is in the same directory as vars.yml file when
-// this prefix is used in the key path.
-// Consider that a very hacky PoC that should be replaced with a proper implementation in the future.
-const inventoryPrefixWorkaround = "{{ playbook_dir }}/../../inventory/host_vars/{{ inventory_hostname }}/"
-
-// GetHost returns a host from the inventory
-func GetHost(hostsini, limit string, defaults *config.Defaults) *ansible.Host {
-	inv := ansible.ParseInventory("ansible.cfg", hostsini, limit)
-	if inv == nil {
-		logger.Debug("inventory not found")
-		return nil
+// GetHost returns a host matched by the ansible host pattern (e.g. "web01", "web*", "db:&eu[0]") or by the address
+// (e.g. "203.0.113.7", "matrix.example.com") from the inventory of the current project,
+// or from the configured inventories if nothing matched locally.
+// If the pattern matches several hosts, the user is asked to pick one; if nothing matched, similar hosts are suggested
+func GetHost(cfg *config.Config, pattern string) *ansible.Host {
+	invs := newInventories(cfg)
+	if host := lookupHost(invs, pattern); host != nil {
+		return host
 	}
-	host := inv.Hosts[limit]
-	if host == nil {
-		logger.Debug("host", limit, "not found in inventory")
+	return suggestHost(invs, pattern)
+}
+
+// LookupHost is the same as GetHost, but it does not suggest similar hosts if nothing matched
+func LookupHost(cfg *config.Config, pattern string) *ansible.Host {
+	return lookupHost(newInventories(cfg), pattern)
+}
+
+// lookupHost implements LookupHost using the inventories loaded within the current lookup
+func lookupHost(invs *inventories, pattern string) *ansible.Host {
+	cfg := invs.cfg
+	hosts := lookupCandidates(invs, pattern)
+	switch len(hosts) {
+	case 0:
 		return nil
+	case 1:
+		logger.Debug("host", hosts[0].Name, "has been found in", hosts[0].Vars.String("inventory_file"))
+		return withDefaults(cfg, hosts[0])
+	default:
+		return withDefaults(cfg, chooseHost(pattern, hosts))
+	}
+}
+
+// lookupCandidates returns the hosts matched by the pattern (or the address) within the inventory of the current project,
+// or within the configured inventories if nothing matched locally
+func lookupCandidates(invs *inventories, pattern string) []*ansible.Host {
+	cfg := invs.cfg
+	hosts := lookupHosts(invs, []source{localSource(cfg)}, pattern)
+	if len(hosts) == 0 {
+		logger.Debug("host", pattern, "not found in inventory")
+		if len(cfg.Inventories) > 0 {
+			hosts = lookupHosts(invs, registrySources(cfg), pattern)
+		}
+	}
+	return hosts
+}
+
+// Resolver returns the function that looks up the host by name (see LookupHost)
+func Resolver(cfg *config.Config) func(name string) *ansible.Host {
+	return func(name string) *ansible.Host {
+		return LookupHost(cfg, name)
 	}
-	host = ansible.MergeHost(host, &ansible.Host{
+}
+
+// AllHosts returns all hosts from the inventory of the current project and the configured inventories
+func AllHosts(cfg *config.Config) []*ansible.Host {
+	return ListHosts(cfg, "")
+}
+
+// ListHosts returns the hosts matched by the ansible host pattern (all hosts if the pattern is empty)
+// from the inventory of the current project and the configured inventories
+func ListHosts(cfg *config.Config, pattern string) []*ansible.Host {
+	hosts := findHosts(cfg, append([]source{localSource(cfg)}, registrySources(cfg)...), pattern)
+	for i, host := range hosts {
+		hosts[i] = withDefaults(cfg, host)
+	}
+	return hosts
+}
+
+// GroupNames returns the sorted host groups, without "all"
+func GroupNames(host *ansible.Host) []string {
+	names := slices.DeleteFunc(slices.Clone(host.Groups), func(group string) bool { return group == allGroup })
+	sort.Strings(names)
+	return names
+}
+
+// HostID returns the unique host id: the host name and the inventory file, e.g. web01@/path/to/hosts
+func HostID(host *ansible.Host) string {
+	return host.Name + "@" + host.Vars.String("inventory_file")
+}
+
+// Where returns all hosts with the name from the inventory of the current project and the configured inventories
+func Where(cfg *config.Config, name string) []*ansible.Host {
+	hosts := findHosts(cfg, append([]source{localSource(cfg)}, registrySources(cfg)...), name)
+	return slices.DeleteFunc(hosts, func(host *ansible.Host) bool { return host.Name != name })
+}
+
+// lookupHosts returns the hosts matched by the pattern within the sources, or the hosts with such address if nothing matched.
+// Every source is parsed once, both the name and the address are matched against the same loaded inventory
+func lookupHosts(invs *inventories, sources []source, pattern string) []*ansible.Host {
+	hosts := []*ansible.Host{}
+	seen := map[string]bool{}
+	for _, src := range sources {
+		l := invs.load(src)
+		if l == nil {
+			continue
+		}
+		names, err := l.match(pattern)
+		if err != nil {
+			logger.Println(err)
+			continue
+		}
+		for _, host := range l.resolve(names) {
+			if id := HostID(host); !seen[id] {
+				seen[id] = true
+				hosts = append(hosts, host)
+			}
+		}
+	}
+	if len(hosts) == 0 {
+		hosts = findHostsByAddress(invs, sources, pattern)
+		if len(hosts) > 0 {
+			logger.Debug("host", pattern, "has been found by address")
+		}
+	}
+	return hosts
+}
+
+// chooseHost asks the user to pick one of the hosts matched by the pattern (possibly, from different inventories),
+// and fails with the list of hosts if the user cannot be asked
+func chooseHost(pattern string, hosts []*ansible.Host) *ansible.Host {
+	items := hostItems(hosts)
+	idx, err := prompt.Choose(pattern+" matches several hosts:", items)
+	if err != nil {
+		logger.Fatal(pattern, " matches several hosts:\n  ", strings.Join(items, "\n  "))
+	}
+	return hosts[idx]
+}
+
+// hostItems returns the hosts as the list items: name (address, inventory file)
+func hostItems(hosts []*ansible.Host) []string {
+	items := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		items = append(items, fmt.Sprintf("%s (%s, %s)", host.Name, host.Host, host.Vars.String("inventory_file")))
+	}
+	return items
+}
+
+// withDefaults sets the config defaults to the host fields that are not defined in the inventory
+func withDefaults(cfg *config.Config, host *ansible.Host) *ansible.Host {
+	defaults := &cfg.Defaults
+	return ansible.MergeHost(host, &ansible.Host{
 		User:        defaults.User,
 		Port:        defaults.Port,
 		SSHPass:     defaults.SSHPass,
 		BecomePass:  defaults.BecomePass,
 		PrivateKeys: defaults.PrivateKeys,
 	})
-
-	// replace inventoryPrefixWorkaround with the actual path,
-	// details are in the inventoryPrefixWorkaround const description
-	for _, invPath := range inv.Paths {
-		invPath = strings.TrimSuffix(invPath, "hosts")
-		for i, key := range host.PrivateKeys {
-			if strings.HasPrefix(key, inventoryPrefixWorkaround) {
-				keypath := strings.Replace(key, inventoryPrefixWorkaround, invPath+"host_vars/"+host.Name+"/", 1)
-				host.PrivateKeys[i] = keypath
-			}
-		}
-	}
-
-	return host
 }
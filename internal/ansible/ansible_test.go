@@ -0,0 +1,48 @@
+package ansible
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/etkecc/ansible-ssh/internal/config"
+)
+
+func TestLookupHostsParsesOnce(t *testing.T) {
+	t.Setenv("ANSIBLE_INVENTORY", "")
+	tests := []struct {
+		name     string
+		pattern  string
+		expected []string
+	}{
+		{"name", "web01", []string{"web01"}},
+		{"group", "web", []string{"web03", "web01", "web02"}}, // the inventory order: the script groups are sorted by name
+		{"address", "10.0.0.1", []string{"web01"}},
+		{"not found", "203.0.113.7", []string{}},
+		{"not found name", "db01", []string{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tempCacheHome(t)
+			dir := t.TempDir()
+			script, runs := writeScript(t, dir, "cat <<'EOF'\n"+scriptOutput+"\nEOF")
+			invs := newInventories(&config.Config{})
+			src := source{root: dir, path: script}
+
+			hosts := lookupHosts(invs, []source{src}, test.pattern)
+			names := []string{}
+			for _, host := range hosts {
+				names = append(names, host.Name)
+			}
+			if !slices.Equal(names, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, names)
+			}
+
+			// the suggestions and the repeated lookups reuse the loaded source
+			lookupHosts(invs, []source{src}, test.pattern)
+			suggest(invs, []source{src}, test.pattern)
+			if count := countRuns(t, runs); count != 1 {
+				t.Errorf("expected the inventory script to run once, got %d runs", count)
+			}
+		})
+	}
+}
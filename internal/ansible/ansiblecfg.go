@@ -0,0 +1,155 @@
+package ansible
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/etkecc/ansible-ssh/internal/logger"
+	"github.com/etkecc/go-ansible"
+)
+
+// cfgPathOptions are the ansible.cfg options that contain (comma-separated) paths,
+// relative paths are resolved against the ansible.cfg dir, like ansible does
+var cfgPathOptions = map[string][]string{
+	"defaults":       {"inventory", "private_key_file", "vault_password_file", "vault_identity_list", "playbook_dir"},
+	"ssh_connection": {"control_path_dir"},
+}
+
+// findAnsibleCfg returns the ansible.cfg path using the ansible lookup order:
+// ANSIBLE_CONFIG env var, ansible.cfg in the project dir, ~/.ansible.cfg, /etc/ansible/ansible.cfg.
+// Empty string is returned if none of them exist
+func findAnsibleCfg(root string) string {
+	if env := os.Getenv("ANSIBLE_CONFIG"); env != "" {
+		env = expandHome(env)
+		if info, err := os.Stat(env); err == nil && info.IsDir() {
+			env = filepath.Join(env, "ansible.cfg")
+		}
+		if isFile(env) {
+			logger.Debug("using", env, "from the ANSIBLE_CONFIG env var")
+			return env
+		}
+		logger.Debug("ANSIBLE_CONFIG", env, "does not exist")
+	}
+
+	if local := filepath.Join(root, "ansible.cfg"); isFile(local) {
+		if !isWorldWritable(root) {
+			logger.Debug("using", local)
+			return local
+		}
+		logger.Println("the", root, "dir is world writable, ignoring ansible.cfg within it (like ansible does)")
+	}
+
+	candidates := []string{expandHome("~/.ansible.cfg"), "/etc/ansible/ansible.cfg"}
+	for _, candidate := range candidates {
+		if isFile(candidate) {
+			logger.Debug("using", candidate)
+			return candidate
+		}
+	}
+	logger.Debug("ansible.cfg not found")
+	return ""
+}
+
+// isFile returns true if the path exists and is not a dir
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+// isWorldWritable returns true if the dir is writable by anyone (not checked on windows)
+func isWorldWritable(dir string) bool {
+	if runtime.GOOS == "windows" {
+		return false
+	}
+	info, err := os.Stat(dir)
+	return err == nil && info.Mode().Perm()&0o002 != 0
+}
+
+// parseAnsibleCfg parses ansible.cfg into the go-ansible structure.
+// Unlike go-ansible's NewAnsibleCfgFile, it keeps values with spaces and "=" (e.g. ssh_args = -o ControlMaster=auto),
+// and follows python's configparser (used by ansible): "key = value" and "key: value" forms, inline "; comments",
+// indented continuation lines and "%%" escapes
+func parseAnsibleCfg(f string) (*ansible.AnsibleCfg, error) {
+	fh, err := os.Open(f)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+
+	cfg := &ansible.AnsibleCfg{Config: map[string]map[string]string{}}
+	section := ""
+	key := ""
+	scanner := bufio.NewScanner(fh)
+	for scanner.Scan() {
+		raw := scanner.Text()
+		line := strings.TrimSpace(raw)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if idx := strings.Index(line, " ;"); idx != -1 {
+			line = strings.TrimSpace(line[:idx])
+		}
+
+		if key != "" && (raw[0] == ' ' || raw[0] == '\t') { // continuation line
+			cfg.Config[section][key] += "\n" + unescapeCfgValue(line)
+			continue
+		}
+		key = ""
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			if cfg.Config[section] == nil {
+				cfg.Config[section] = map[string]string{}
+			}
+			continue
+		}
+		if section == "" {
+			continue
+		}
+
+		idx := strings.IndexAny(line, "=:")
+		if idx == -1 {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(line[:idx]))
+		cfg.Config[section][key] = unescapeCfgValue(strings.TrimSpace(line[idx+1:]))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	anchorCfgPaths(cfg, filepath.Dir(f))
+
+	return cfg, nil
+}
+
+// anchorCfgPaths resolves relative paths of the ansible.cfg path options against the ansible.cfg dir
+func anchorCfgPaths(cfg *ansible.AnsibleCfg, dir string) {
+	for section, options := range cfgPathOptions {
+		for _, option := range options {
+			value := cfg.Config[section][option]
+			if value == "" {
+				continue
+			}
+			items := strings.Split(value, ",")
+			for i, item := range items {
+				label, itemPath, ok := strings.Cut(strings.TrimSpace(item), "@") // vault_identity_list: label@path
+				if !ok {
+					label, itemPath = "", label
+				}
+				items[i] = anchorPath(dir, itemPath)
+				if ok {
+					items[i] = label + "@" + items[i]
+				}
+			}
+			cfg.Config[section][option] = strings.Join(items, ",")
+		}
+	}
+}
+
+// unescapeCfgValue replaces configparser's "%%" escapes with "%"
+func unescapeCfgValue(value string) string {
+	return strings.ReplaceAll(value, "%%", "%")
+}
@@ -0,0 +1,292 @@
+package ansible
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/etkecc/ansible-ssh/internal/config"
+	"github.com/etkecc/go-ansible"
+)
+
+// Origin is a value of the connection setting and the place where it has been defined
+type Origin struct {
+	Value  string // raw value, as defined in the source (before templating)
+	Var    string // var name or option, e.g. ansible_user or remote_user
+	Source string // file path (with the line number, if known) or env var
+}
+
+// Setting is the resolved connection setting with its origin and the values it has overridden
+type Setting struct {
+	Name      string   // setting name, e.g. "user"
+	Value     string   // the final value
+	Secret    bool     // the value is a password
+	Origin    *Origin  // nil if the setting is not defined anywhere
+	Overrides []Origin // overridden values, from the highest precedence to the lowest
+}
+
+// Explanation describes how the connection settings of the host have been resolved
+type Explanation struct {
+	Host     *ansible.Host
+	Settings []Setting
+}
+
+// explainSetting is the connection setting with its vars (aliases) and the fallbacks of the lower precedence
+type explainSetting struct {
+	name     string
+	vars     []string
+	secret   bool
+	value    func(host *ansible.Host) string
+	fallback func(e *explainer) []Origin     // lower precedence origins (ansible.cfg, ansible-ssh config), optional
+	implicit func(host *ansible.Host) Origin // the value used when the setting is not defined anywhere, optional
+}
+
+// explainer explains the connection settings using the var origins recorded by the vars loader
+type explainer struct {
+	cfg     *config.Config
+	src     source
+	acfg    *ansible.AnsibleCfg
+	origins map[string][]Origin // var origins, from the lowest precedence to the highest
+}
+
+// Explain returns the host (matched the same way as LookupHost, but the user is never asked to pick one of several hosts)
+// and the origins of its connection settings: inventory files, group_vars and host_vars files, ansible.cfg options,
+// env vars and the ansible-ssh config defaults
+func Explain(cfg *config.Config, pattern string) (*Explanation, error) {
+	invs := newInventories(cfg)
+	hosts := lookupCandidates(invs, pattern)
+	switch len(hosts) {
+	case 0:
+		return nil, errors.New("host " + pattern + " not found")
+	case 1:
+	default:
+		return nil, errors.New(pattern + " matches several hosts:\n  " + strings.Join(hostItems(hosts), "\n  "))
+	}
+	host := hosts[0]
+	src, l, ok := invs.owner(host)
+	if !ok {
+		return nil, errors.New("cannot find the inventory of " + host.Name)
+	}
+	acfg, err := parseAnsibleCfg(src.ansibleCfg)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	e := &explainer{cfg: cfg, src: src, acfg: acfg, origins: l.loader.Origins(l.inv, host)}
+	host = withDefaults(cfg, host)
+	explanation := &Explanation{Host: host}
+	for _, setting := range e.settings() {
+		explanation.Settings = append(explanation.Settings, e.explain(host, setting))
+	}
+	return explanation, nil
+}
+
+// settings returns the explained connection settings
+func (e *explainer) settings() []explainSetting {
+	defaults := e.cfg.Defaults
+	settings := []explainSetting{
+		{
+			name: "address", vars: hostVarNames,
+			value:    func(host *ansible.Host) string { return host.Host },
+			implicit: func(host *ansible.Host) Origin { return Origin{Value: host.Name, Source: "inventory hostname"} },
+		},
+		{
+			name: "port", vars: portVarNames,
+			value: func(host *ansible.Host) string { return intString(host.Port) },
+			fallback: func(e *explainer) []Origin {
+				return append(e.cfgOrigins("defaults", "remote_port", "ANSIBLE_REMOTE_PORT"), configOrigin("defaults.port", intString(defaults.Port))...)
+			},
+		},
+		{
+			name: "user", vars: userVarNames,
+			value: func(host *ansible.Host) string { return host.User },
+			fallback: func(e *explainer) []Origin {
+				return append(e.cfgOrigins("defaults", "remote_user", "ANSIBLE_REMOTE_USER"), configOrigin("defaults.user", defaults.User)...)
+			},
+		},
+		{
+			name: "ssh password", vars: passVarNames, secret: true,
+			value:    func(host *ansible.Host) string { return host.SSHPass },
+			fallback: func(*explainer) []Origin { return configOrigin("defaults.ssh_password", defaults.SSHPass) },
+		},
+		{
+			name: "become password", vars: becomePassVarNames, secret: true,
+			value:    func(host *ansible.Host) string { return host.BecomePass },
+			fallback: func(*explainer) []Origin { return configOrigin("defaults.become_password", defaults.BecomePass) },
+		},
+		{
+			name: "private keys", vars: keyVarNames,
+			value: func(host *ansible.Host) string { return strings.Join(host.PrivateKeys, ", ") },
+			fallback: func(e *explainer) []Origin {
+				origins := e.cfgOrigins("defaults", "private_key_file", "ANSIBLE_PRIVATE_KEY_FILE")
+				return append(origins, configOrigin("defaults.private_keys", strings.Join(defaults.PrivateKeys, ", "))...)
+			},
+		},
+	}
+
+	for _, name := range extraVarNames {
+		vars := []string{name}
+		for _, aliases := range extraVarAliases {
+			if aliases[0] == name {
+				vars = aliases
+			}
+		}
+		settings = append(settings, explainSetting{
+			name: name, vars: vars,
+			value: func(host *ansible.Host) string { return host.Vars.String(name) },
+			fallback: func(e *explainer) []Origin {
+				origins := []Origin{}
+				for i := len(ansibleCfgVars) - 1; i >= 0; i-- { // the later options win, see varsFromAnsibleCfg
+					if option := ansibleCfgVars[i]; option.name == name {
+						origins = append(origins, e.cfgOrigins(option.section, option.option, option.env)...)
+					}
+				}
+				return origins
+			},
+		})
+	}
+	return settings
+}
+
+// explain returns the setting value with its origin and overridden values
+func (e *explainer) explain(host *ansible.Host, setting explainSetting) Setting {
+	result := Setting{Name: setting.name, Value: setting.value(host), Secret: setting.secret}
+
+	// aliases: the last defined var wins (see lastVar)
+	candidates := []Origin{}
+	for i := len(setting.vars) - 1; i >= 0; i-- {
+		origins := e.origins[setting.vars[i]]
+		for j := len(origins) - 1; j >= 0; j-- {
+			if origins[j].Value != "" {
+				candidates = append(candidates, origins[j])
+			}
+		}
+	}
+	if setting.fallback != nil {
+		candidates = append(candidates, setting.fallback(e)...)
+	}
+	if len(candidates) == 0 && setting.implicit != nil {
+		candidates = append(candidates, setting.implicit(host))
+	}
+	if len(candidates) == 0 {
+		return result
+	}
+	result.Origin = &candidates[0]
+	result.Overrides = candidates[1:]
+	return result
+}
+
+// cfgOrigins returns the origins of the ansible.cfg option and its env var (the env var goes first as it takes precedence)
+func (e *explainer) cfgOrigins(section, option, env string) []Origin {
+	origins := []Origin{}
+	if value := os.Getenv(env); env != "" && value != "" {
+		origins = append(origins, Origin{Value: value, Var: env, Source: "env"})
+	}
+	if e.acfg != nil {
+		if value := e.acfg.Config[section][option]; value != "" {
+			src := fileLine(e.src.ansibleCfg, regexp.MustCompile(`^\s*\[`+regexp.QuoteMeta(section)+`\]`), option)
+			origins = append(origins, Origin{Value: value, Var: "[" + section + "] " + option, Source: src})
+		}
+	}
+	return origins
+}
+
+// configOrigin returns the origin of the ansible-ssh config option, if it is set
+func configOrigin(option, value string) []Origin {
+	if value == "" {
+		return nil
+	}
+	return []Origin{{Value: value, Var: option, Source: "ansible-ssh config"}}
+}
+
+// intString converts the number into string, 0 is converted into empty string
+func intString(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
+
+// sortedKeys returns the sorted map keys
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// groupAnchor matches the start of the group vars within the INI ([group:vars]) or YAML (group:) inventory
+func groupAnchor(group string) *regexp.Regexp {
+	return regexp.MustCompile(`^\s*(\[` + regexp.QuoteMeta(group) + `:vars\]|` + regexp.QuoteMeta(group) + `:\s*$)`)
+}
+
+// hostAnchor matches the host line within the INI (host key=value) or YAML (host:) inventory
+func hostAnchor(name string) *regexp.Regexp {
+	return regexp.MustCompile(`^\s*` + regexp.QuoteMeta(name) + `(\s|:|$)`)
+}
+
+// hostLine returns the file path with the number of the line that defines the host var, the same as fileLine.
+// The INI host line may define the port as name:port, then hostPort is true
+func hostLine(file, name, key string) (src string, hostPort bool) {
+	fh, err := os.Open(file)
+	if err != nil {
+		return file, false
+	}
+	defer fh.Close()
+
+	anchor := hostAnchor(name)
+	portLine := regexp.MustCompile(`^\s*` + regexp.QuoteMeta(name) + `:[0-9]+(\s|$)`)
+	keyLine := regexp.MustCompile(`(^|\s)"?` + regexp.QuoteMeta(key) + `"?\s*[=:]`)
+	var anchored bool // the YAML host, its vars follow the host line
+	scanner := bufio.NewScanner(fh)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := scanner.Text()
+		if anchor.MatchString(line) {
+			switch {
+			case keyLine.MatchString(strings.TrimPrefix(strings.TrimSpace(line), name)):
+				return file + ":" + strconv.Itoa(lineNum), false
+			case key == "ansible_port" && portLine.MatchString(line):
+				return file + ":" + strconv.Itoa(lineNum), true
+			}
+			anchored = strings.HasSuffix(strings.TrimSpace(line), ":")
+			continue
+		}
+		if anchored && keyLine.MatchString(line) {
+			return file + ":" + strconv.Itoa(lineNum), false
+		}
+	}
+	return file, false
+}
+
+// fileLine returns the file path with the number of the line that defines the key (after the anchor line, if set),
+// the file path only if the line cannot be found (e.g. vault-encrypted files or inventory scripts)
+func fileLine(file string, anchor *regexp.Regexp, key string) string {
+	fh, err := os.Open(file)
+	if err != nil {
+		return file
+	}
+	defer fh.Close()
+
+	keyLine := regexp.MustCompile(`(^|\s)"?` + regexp.QuoteMeta(key) + `"?\s*[=:]`)
+	anchored := anchor == nil
+	scanner := bufio.NewScanner(fh)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := scanner.Text()
+		if !anchored {
+			if !anchor.MatchString(line) {
+				continue
+			}
+			anchored = true
+		}
+		if keyLine.MatchString(line) {
+			return file + ":" + strconv.Itoa(lineNum)
+		}
+	}
+	return file
+}
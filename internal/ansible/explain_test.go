@@ -0,0 +1,82 @@
+package ansible
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/etkecc/ansible-ssh/internal/config"
+)
+
+func TestExplain(t *testing.T) {
+	t.Setenv("ANSIBLE_INVENTORY", "")
+	t.Setenv("ANSIBLE_REMOTE_USER", "")
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"hosts":                 "[web]\nweb01:2222 ansible_host=10.0.0.1\nweb02 ansible_port=2200\n\n[web:vars]\nansible_user=deploy\n",
+		"group_vars/all.yml":    "ansible_user: root\n",
+		"host_vars/web02.yml":   "---\nansible_user: admin\n",
+		"host_vars/web01/a.yml": "ansible_ssh_timeout: \"15\"\n",
+	})
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(root); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(cwd) }) //nolint:errcheck // nothing to do with the error here
+	cfg := &config.Config{Path: "hosts", Defaults: config.Defaults{Port: 22}}
+
+	hosts := filepath.Join(root, "hosts")
+	tests := []struct {
+		name      string
+		host      string
+		setting   string
+		value     string
+		originVar string
+		source    string
+		overrides int
+	}{
+		{"INI host port", "web01", "port", "2222", "host:port", hosts + ":2", 1},
+		{"inventory host var", "web02", "port", "2200", "ansible_port", hosts + ":3", 1},
+		{"group_vars file over inventory group vars", "web01", "user", "root", "ansible_user", filepath.Join(root, "group_vars/all.yml") + ":1", 1},
+		{"host_vars file", "web02", "user", "admin", "ansible_user", filepath.Join(root, "host_vars/web02.yml") + ":2", 2},
+		{"host_vars dir", "web01", "ansible_ssh_timeout", "15", "ansible_ssh_timeout", filepath.Join(root, "host_vars/web01/a.yml") + ":1", 0},
+		{"address", "web01", "address", "10.0.0.1", "ansible_host", hosts + ":2", 0},
+		{"implicit address", "web02", "address", "web02", "", "inventory hostname", 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			explanation, err := Explain(cfg, test.host)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, setting := range explanation.Settings {
+				if setting.Name != test.setting {
+					continue
+				}
+				if setting.Value != test.value {
+					t.Errorf("expected value %q, got %q", test.value, setting.Value)
+				}
+				if setting.Origin == nil {
+					t.Fatal("expected the origin, got nil")
+				}
+				if setting.Origin.Var != test.originVar || setting.Origin.Source != test.source {
+					t.Errorf("expected the origin %s (%s), got %s (%s)", test.originVar, test.source, setting.Origin.Var, setting.Origin.Source)
+				}
+				if len(setting.Overrides) != test.overrides {
+					t.Errorf("expected %d overridden values, got %v", test.overrides, setting.Overrides)
+				}
+				return
+			}
+			t.Errorf("setting %s not found", test.setting)
+		})
+	}
+
+	for _, pattern := range []string{"web", "db01"} {
+		if _, err := Explain(cfg, pattern); err == nil {
+			t.Errorf("expected an error for %s", pattern)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package ansible
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/etkecc/ansible-ssh/internal/shell"
+	"github.com/etkecc/go-ansible"
+)
+
+// hostRange matches the host range pattern, e.g. web[01:10] or db-[a:f], with optional step: [1:10:2]
+var hostRange = regexp.MustCompile(`\[([0-9]+|[a-zA-Z]):([0-9]+|[a-zA-Z])(?::([0-9]+))?\]`)
+
+// parseINIHostsFile parses INI inventory file into the same structure as YAML inventory,
+// unlike go-ansible's NewHostsFile, it keeps all inline host vars and supports quoted values and host ranges
+func parseINIHostsFile(f string, defaults *ansible.Host) (*ansible.Inventory, error) {
+	fh, err := os.Open(f)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+
+	tree := newInventoryTree()
+	section := ungroupedGroup
+	kind := ""
+	scanner := bufio.NewScanner(fh)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section, kind, _ = strings.Cut(strings.TrimSpace(line[1:len(line)-1]), ":")
+			tree.addGroup(section, "")
+			continue
+		}
+
+		switch kind {
+		case "vars":
+			key, value, ok := strings.Cut(line, "=")
+			if !ok {
+				return nil, fmt.Errorf("%s:%d: expected key=value in [%s:vars]", f, lineNum, section)
+			}
+			tree.addGroupVars(section, map[string]any{strings.TrimSpace(key): unquote(strings.TrimSpace(value))})
+		case "children":
+			tree.addGroup(strings.Fields(line)[0], section)
+		default:
+			if err := parseINIHost(tree, section, line); err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", f, lineNum, err)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	tree.linkTopGroups()
+
+	return tree.inventory(defaults), nil
+}
+
+// parseINIHost parses the host line: name[:port] key=value key="quoted value"
+func parseINIHost(tree *inventoryTree, group, line string) error {
+	words, err := shell.Split(line)
+	if err != nil {
+		return err
+	}
+	if len(words) == 0 {
+		return nil
+	}
+
+	vars := map[string]any{}
+	for _, word := range words[1:] {
+		if key, value, ok := strings.Cut(word, "="); ok {
+			vars[key] = value
+		}
+	}
+	for _, pattern := range expandHostRange(words[0]) {
+		name, port := splitHostPort(pattern)
+		hostVars := vars
+		if port != "" && vars["ansible_port"] == nil {
+			hostVars = map[string]any{"ansible_port": port}
+			for k, v := range vars {
+				hostVars[k] = v
+			}
+		}
+		tree.addHost(name, group, hostVars)
+	}
+	return nil
+}
+
+// splitHostPort splits host:port and [ipv6]:port, host without port is returned as is
+func splitHostPort(pattern string) (host, port string) {
+	if strings.HasPrefix(pattern, "[") {
+		if end := strings.Index(pattern, "]:"); end != -1 {
+			if _, err := strconv.Atoi(pattern[end+2:]); err == nil {
+				return pattern[1:end], pattern[end+2:]
+			}
+		}
+		return pattern, ""
+	}
+	if strings.Count(pattern, ":") == 1 {
+		host, port, _ = strings.Cut(pattern, ":")
+		if _, err := strconv.Atoi(port); err == nil {
+			return host, port
+		}
+	}
+	return pattern, ""
+}
+
+// expandHostRange expands host ranges, e.g. web[01:03] into web01, web02, web03
+func expandHostRange(pattern string) []string {
+	loc := hostRange.FindStringSubmatchIndex(pattern)
+	if loc == nil {
+		return []string{pattern}
+	}
+	prefix, suffix := pattern[:loc[0]], pattern[loc[1]:]
+	start, end := pattern[loc[2]:loc[3]], pattern[loc[4]:loc[5]]
+	step := 1
+	if loc[6] != -1 {
+		if s, err := strconv.Atoi(pattern[loc[6]:loc[7]]); err == nil && s > 0 {
+			step = s
+		}
+	}
+
+	items := []string{}
+	startNum, startErr := strconv.Atoi(start)
+	endNum, endErr := strconv.Atoi(end)
+	switch {
+	case startErr == nil && endErr == nil:
+		format := "%d"
+		if len(start) > 1 && start[0] == '0' {
+			format = "%0" + strconv.Itoa(len(start)) + "d"
+		}
+		for i := startNum; i <= endNum; i += step {
+			items = append(items, fmt.Sprintf(format, i))
+		}
+	case startErr != nil && endErr != nil:
+		for c := start[0]; c <= end[0]; c += byte(step) {
+			items = append(items, string(c))
+		}
+	default:
+		return []string{pattern}
+	}
+
+	expanded := []string{}
+	for _, item := range items {
+		expanded = append(expanded, expandHostRange(prefix+item+suffix)...)
+	}
+	return expanded
+}
+
+// unquote removes matching quotes around the value
+func unquote(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
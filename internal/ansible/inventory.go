@@ -0,0 +1,389 @@
+package ansible
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/etkecc/ansible-ssh/internal/config"
+	"github.com/etkecc/ansible-ssh/internal/logger"
+	"github.com/etkecc/go-ansible"
+)
+
+// inventoryParser parses an inventory source into the inventory structure
+type inventoryParser func(f string, defaults *ansible.Host) (*ansible.Inventory, error)
+
+// yamlKeyLine matches the "key:" line of the YAML inventory, e.g. "all:"
+var yamlKeyLine = regexp.MustCompile(`^[^\s=\[#;]+:\s*(#.*)?$`)
+
+// ParseInventory parses ansible.cfg and all inventory sources (the configured path and the ansible.cfg inventory),
+// picking the parser for each source by its file extension, executable bit or content.
+// Relative paths of the configured inventory and private keys are resolved against the root dir.
+// If the limit (ansible host pattern) is set, only the matched hosts are kept
+func ParseInventory(cfg *config.Config, root, ansibleCfg, limit string) *ansible.Inventory {
+	l := loadInventory(cfg, root, ansibleCfg)
+	if l == nil {
+		return nil
+	}
+	names, err := l.match(limit)
+	if err != nil {
+		logger.Println(err)
+		return nil
+	}
+
+	hosts := l.resolve(names)
+	names = make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		names = append(names, host.Name)
+	}
+	filterInventory(l.inv, names)
+	return l.inv
+}
+
+// loadedInventory is the parsed inventory structure, the vars of the hosts are loaded and rendered on demand (see resolve),
+// so matching the hosts does not load the vars files (and does not decrypt the vault values) of the hosts that are not matched
+type loadedInventory struct {
+	inv       *ansible.Inventory
+	root      string
+	cfgVars   map[string]any
+	loader    *varsLoader
+	rawLoader *varsLoader // see rawHost, created on demand
+	pbDir     string
+	resolved  map[string]bool // host name -> the host can be used (false if it has been skipped)
+}
+
+// loadInventory parses ansible.cfg and all inventory sources (see ParseInventory) without loading the host vars,
+// returns nil if there are no hosts
+func loadInventory(cfg *config.Config, root, ansibleCfg string) *loadedInventory {
+	acfg, err := parseAnsibleCfg(ansibleCfg)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		logger.Println("cannot parse", ansibleCfg, "error:", err)
+		return nil
+	}
+
+	defaults := defaultsFromAnsibleCfg(acfg)
+	v := newVault(cfg, acfg)
+	loader := newVarsLoader(v)
+	inv := &ansible.Inventory{}
+	for _, invPath := range inventoryPaths(anchorPath(root, cfg.Path), acfg) {
+		parsed, err := detectParser(cfg, v, invPath)(invPath, defaults)
+		if err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				logger.Println("cannot parse", invPath, "error:", err)
+			}
+			continue
+		}
+		parsed.Paths = []string{invPath}
+		setInventorySource(parsed, invPath)
+		loader.addInventory(invPath, parsed)
+		mergeInventory(inv, parsed)
+	}
+	if len(inv.Hosts) == 0 {
+		return nil
+	}
+
+	return &loadedInventory{
+		inv:      inv,
+		root:     root,
+		cfgVars:  varsFromAnsibleCfg(acfg),
+		loader:   loader,
+		pbDir:    playbookDir(cfg, acfg, root),
+		resolved: map[string]bool{},
+	}
+}
+
+// match returns the names of the hosts matched by the ansible host pattern, all hosts if the pattern is empty
+func (l *loadedInventory) match(pattern string) ([]string, error) {
+	if pattern == "" {
+		names := make([]string, 0, len(l.inv.Hosts))
+		for _, host := range l.inv.Groups[allGroup] {
+			names = append(names, host.Name)
+		}
+		return names, nil
+	}
+	return matchPattern(l.inv, pattern)
+}
+
+// resolve loads the vars of the hosts and renders their templates (once per host), and returns the hosts in the inventory order.
+// The hosts whose connection vars cannot be decrypted are skipped
+func (l *loadedInventory) resolve(names []string) []*ansible.Host {
+	hosts := make([]*ansible.Host, 0, len(names))
+	for _, host := range l.inv.Groups[allGroup] {
+		if !slices.Contains(names, host.Name) {
+			continue
+		}
+		usable, ok := l.resolved[host.Name]
+		if !ok {
+			usable = l.resolveHost(host)
+			l.resolved[host.Name] = usable
+		}
+		if usable {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+// rawHost returns the host with the connection fields and vars as defined within the sources (the templates are not rendered,
+// the vault values are not decrypted), the host itself if it has been resolved already
+func (l *loadedInventory) rawHost(host *ansible.Host) *ansible.Host {
+	if _, ok := l.resolved[host.Name]; ok {
+		return host
+	}
+	if l.rawLoader == nil {
+		l.rawLoader = l.loader.withoutVault()
+	}
+	raw := hostFromVars(host.Name, l.rawLoader.HostVars(l.inv, host))
+	if raw.Host == "" {
+		raw.Host = host.Host
+	}
+	return raw
+}
+
+// resolveHost loads the vars of the host and renders its templates, returns false if the host cannot be used
+func (l *loadedInventory) resolveHost(host *ansible.Host) bool {
+	host.Vars = l.loader.HostVars(l.inv, host)
+	resolveVarAliases(host.Vars)
+	for k, v := range l.cfgVars {
+		if _, ok := host.Vars[k]; !ok {
+			host.Vars[k] = v
+		}
+	}
+	overrideHost(host, hostFromVars(host.Name, host.Vars))
+
+	renderHost(host, templateVars(host, l.pbDir))
+	for i, key := range host.PrivateKeys {
+		host.PrivateKeys[i] = anchorPath(l.root, key)
+	}
+	connection, other := undecryptableVars(host)
+	if len(other) > 0 {
+		logger.Warn("cannot decrypt", strings.Join(other, ", "), "of", host.Name, "(wrong vault password?), the values are not used for the connection")
+	}
+	if len(connection) > 0 {
+		logger.Warn("cannot decrypt", strings.Join(connection, ", "), "of", host.Name, "(wrong vault password?), skipping the host")
+		return false
+	}
+	return true
+}
+
+// setInventorySource sets the inventory_file and inventory_dir magic vars of the inventory hosts
+func setInventorySource(inv *ansible.Inventory, invPath string) {
+	if abs, err := filepath.Abs(invPath); err == nil {
+		invPath = abs
+	}
+	for _, host := range inv.Hosts {
+		if host.Vars == nil {
+			host.Vars = ansible.HostVars{}
+		}
+		host.Vars["inventory_file"] = invPath
+		host.Vars["inventory_dir"] = filepath.Dir(invPath)
+	}
+}
+
+// detectParser picks the inventory parser by the file extension, executable bit and shebang (dynamic inventory script),
+// or by the file content if nothing else matched
+func detectParser(cfg *config.Config, v *vault, invPath string) inventoryParser {
+	switch strings.ToLower(filepath.Ext(invPath)) {
+	case ".yml", ".yaml", ".json":
+		return newYAMLParser(v)
+	case ".ini", ".cfg":
+		return parseINIHostsFile
+	}
+	if isInventoryScript(invPath) {
+		logger.Debug("inventory", invPath, "is an executable, treating it as a dynamic inventory script")
+		return newScriptParser(&cfg.Script)
+	}
+
+	fh, err := os.Open(invPath)
+	if err != nil {
+		return parseINIHostsFile
+	}
+	defer fh.Close()
+
+	scanner := bufio.NewScanner(fh)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if line == "---" || strings.HasPrefix(line, "{") || strings.HasPrefix(line, vaultHeader) || yamlKeyLine.MatchString(line) {
+			logger.Debug("inventory", invPath, "looks like YAML")
+			return newYAMLParser(v)
+		}
+		break
+	}
+	return parseINIHostsFile
+}
+
+// inventoryPaths returns the provided inventory path and all paths from the ANSIBLE_INVENTORY env var,
+// or the ansible.cfg inventory option if the env var is not set
+func inventoryPaths(static string, cfg *ansible.AnsibleCfg) []string {
+	all := []string{static}
+	list := os.Getenv("ANSIBLE_INVENTORY")
+	if list == "" && cfg != nil {
+		list = cfg.Config["defaults"]["inventory"]
+	}
+
+	for _, invPath := range strings.Split(list, ",") {
+		invPath = strings.TrimSpace(invPath)
+		if invPath != "" && !slices.Contains(all, invPath) {
+			all = append(all, invPath)
+		}
+	}
+	return all
+}
+
+// defaultsFromAnsibleCfg returns host defaults from the ansible.cfg [defaults] section,
+// the ANSIBLE_REMOTE_USER and ANSIBLE_PRIVATE_KEY_FILE env vars take precedence
+func defaultsFromAnsibleCfg(cfg *ansible.AnsibleCfg) *ansible.Host {
+	base := &ansible.Host{}
+	section := map[string]string{}
+	if cfg != nil && cfg.Config["defaults"] != nil {
+		section = cfg.Config["defaults"]
+	}
+
+	base.User = envOr("ANSIBLE_REMOTE_USER", section["remote_user"])
+	if privkey := envOr("ANSIBLE_PRIVATE_KEY_FILE", section["private_key_file"]); privkey != "" {
+		base.PrivateKeys = []string{privkey}
+	}
+	if port, err := strconv.Atoi(section["remote_port"]); err == nil {
+		base.Port = port
+	}
+	return base
+}
+
+// envOr returns the env var value, or the fallback if the env var is not set
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// varsFromAnsibleCfg returns the connection vars configured within the ansible.cfg [defaults] and [ssh_connection] sections,
+// or the corresponding ANSIBLE_* env vars
+func varsFromAnsibleCfg(cfg *ansible.AnsibleCfg) map[string]any {
+	vars := map[string]any{}
+	for _, option := range ansibleCfgVars {
+		value := ""
+		if cfg != nil {
+			value = cfg.Config[option.section][option.option]
+		}
+		if value = envOr(option.env, value); value != "" {
+			vars[option.name] = value
+		}
+	}
+	return vars
+}
+
+// mergeInventory merges src inventory into dst, hosts that are defined in both inventories are merged, dst values win
+func mergeInventory(dst, src *ansible.Inventory) {
+	if dst.Groups == nil {
+		dst.Groups = map[string][]*ansible.Host{}
+		dst.GroupVars = map[string]map[string]string{}
+		dst.GroupTree = map[string][]string{}
+		dst.Hosts = map[string]*ansible.Host{}
+	}
+	if src == nil {
+		return
+	}
+
+	for _, invPath := range src.Paths {
+		if !slices.Contains(dst.Paths, invPath) {
+			dst.Paths = append(dst.Paths, invPath)
+		}
+	}
+	for group, children := range src.GroupTree {
+		for _, child := range children {
+			if !slices.Contains(dst.GroupTree[group], child) {
+				dst.GroupTree[group] = append(dst.GroupTree[group], child)
+			}
+		}
+	}
+	for group, vars := range src.GroupVars {
+		if dst.GroupVars[group] == nil {
+			dst.GroupVars[group] = map[string]string{}
+		}
+		for k, v := range vars {
+			if _, ok := dst.GroupVars[group][k]; !ok {
+				dst.GroupVars[group][k] = v
+			}
+		}
+	}
+	for name, host := range src.Hosts {
+		dst.Hosts[name] = ansible.MergeHost(dst.Hosts[name], host)
+	}
+
+	for group, hosts := range src.Groups {
+		for _, host := range hosts {
+			if !slices.ContainsFunc(dst.Groups[group], func(h *ansible.Host) bool { return h.Name == host.Name }) {
+				dst.Groups[group] = append(dst.Groups[group], dst.Hosts[host.Name])
+			}
+		}
+	}
+}
+
+// filterInventory removes the hosts that are not in the list from the inventory, the groups order is kept
+func filterInventory(inv *ansible.Inventory, names []string) {
+	for name := range inv.Hosts {
+		if !slices.Contains(names, name) {
+			delete(inv.Hosts, name)
+		}
+	}
+	for group, hosts := range inv.Groups {
+		inv.Groups[group] = slices.DeleteFunc(hosts, func(host *ansible.Host) bool { return inv.Hosts[host.Name] == nil })
+	}
+}
+
+// expandHome replaces the leading ~ with the user's home dir
+func expandHome(p string) string {
+	if p != "~" && !strings.HasPrefix(p, "~/") {
+		return p
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return p
+	}
+	return filepath.Join(home, strings.TrimPrefix(p, "~"))
+}
+
+// anchorPath resolves the relative path against the dir, ~ is expanded to the user's home dir
+func anchorPath(dir, p string) string {
+	p = expandHome(strings.TrimSpace(p))
+	if p == "" || filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(dir, p)
+}
+
+// projectDir returns the nearest dir that contains ansible.cfg or the inventory file,
+// starting from the current dir and going up (like git looks for .git), or the current dir if nothing found
+func projectDir(invPath string) string {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "."
+	}
+	invPath = expandHome(invPath)
+	for dir := cwd; ; dir = filepath.Dir(dir) {
+		if isFile(filepath.Join(dir, "ansible.cfg")) || (invPath != "" && !filepath.IsAbs(invPath) && fileExists(filepath.Join(dir, invPath))) {
+			if dir != cwd {
+				logger.Debug("project dir", dir, "has been found")
+			}
+			return dir
+		}
+		if filepath.Dir(dir) == dir {
+			return cwd
+		}
+	}
+}
+
+// fileExists returns true if the path exists
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,125 @@
+package ansible
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/etkecc/go-ansible"
+)
+
+// connection vars and their aliases, if several aliases are defined, the last one wins (like in the ansible ssh connection plugin)
+var (
+	hostVarNames       = []string{"ansible_host", "ansible_ssh_host"}
+	portVarNames       = []string{"ansible_port", "ansible_ssh_port"}
+	userVarNames       = []string{"ansible_user", "ansible_ssh_user"}
+	passVarNames       = []string{"ansible_password", "ansible_ssh_pass", "ansible_ssh_password"}
+	becomePassVarNames = []string{"ansible_become_password", "ansible_become_pass"}
+	keyVarNames        = []string{"ansible_private_key_file", "ansible_ssh_private_key_file"}
+	// extraVarNames are the connection vars that do not have a Host field, they are kept (rendered) within the host vars
+	extraVarNames = []string{
+		"ansible_ssh_common_args", "ansible_ssh_extra_args", "ansible_ssh_executable", "ansible_connection",
+		"ansible_ssh_args", "ansible_ssh_timeout", "ansible_host_key_checking", "ansible_control_path_dir", "ansible_control_path",
+	}
+	// extraVarAliases are the aliases of the extra connection vars, the last defined alias is stored under the first name
+	extraVarAliases = [][]string{{"ansible_host_key_checking", "ansible_ssh_host_key_checking"}}
+)
+
+// ansibleCfgVars maps ansible.cfg options and env vars onto the connection vars, the same as in the ansible ssh connection plugin,
+// the options are used when the host does not define the var
+var ansibleCfgVars = []struct {
+	section string
+	option  string
+	env     string
+	name    string
+}{
+	{"defaults", "remote_port", "ANSIBLE_REMOTE_PORT", "ansible_port"},
+	{"ssh_connection", "ssh_args", "ANSIBLE_SSH_ARGS", "ansible_ssh_args"},
+	{"ssh_connection", "ssh_common_args", "ANSIBLE_SSH_COMMON_ARGS", "ansible_ssh_common_args"},
+	{"ssh_connection", "ssh_extra_args", "ANSIBLE_SSH_EXTRA_ARGS", "ansible_ssh_extra_args"},
+	{"ssh_connection", "ssh_executable", "ANSIBLE_SSH_EXECUTABLE", "ansible_ssh_executable"},
+	{"ssh_connection", "control_path_dir", "ANSIBLE_SSH_CONTROL_PATH_DIR", "ansible_control_path_dir"},
+	{"ssh_connection", "control_path", "ANSIBLE_SSH_CONTROL_PATH", "ansible_control_path"},
+	{"defaults", "timeout", "ANSIBLE_TIMEOUT", "ansible_ssh_timeout"},
+	{"ssh_connection", "timeout", "ANSIBLE_SSH_TIMEOUT", "ansible_ssh_timeout"},
+	{"defaults", "host_key_checking", "ANSIBLE_HOST_KEY_CHECKING", "ansible_host_key_checking"},
+	{"ssh_connection", "host_key_checking", "ANSIBLE_SSH_HOST_KEY_CHECKING", "ansible_host_key_checking"},
+}
+
+// hostFromVars converts inventory vars into the host connection fields
+func hostFromVars(name string, vars map[string]any) *ansible.Host {
+	host := &ansible.Host{Name: name, Vars: ansible.HostVars{}}
+	for k, v := range vars {
+		host.Vars[k] = v
+	}
+
+	host.Host = lastVar(vars, hostVarNames)
+	host.Port, _ = strconv.Atoi(lastVar(vars, portVarNames)) //nolint:errcheck // should not be a big problem
+	host.User = lastVar(vars, userVarNames)
+	host.SSHPass = lastVar(vars, passVarNames)
+	host.BecomePass = lastVar(vars, becomePassVarNames)
+	if key := lastVar(vars, keyVarNames); key != "" {
+		host.PrivateKeys = []string{key}
+	}
+	host.OrderedAt = varString(vars["ordered_at"])
+
+	return host
+}
+
+// resolveVarAliases stores the value of the last defined alias of the extra connection vars under the first name
+func resolveVarAliases(vars map[string]any) {
+	for _, aliases := range extraVarAliases {
+		for i := len(aliases) - 1; i >= 0; i-- {
+			if value, ok := vars[aliases[i]]; ok {
+				vars[aliases[0]] = value
+				break
+			}
+		}
+	}
+}
+
+// lastVar returns the value of the last defined var from the list of aliases
+func lastVar(vars map[string]any, names []string) string {
+	for i := len(names) - 1; i >= 0; i-- {
+		if value := varString(vars[names[i]]); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// overrideHost sets non-empty connection fields of src to dst
+func overrideHost(dst, src *ansible.Host) {
+	if src.Host != "" {
+		dst.Host = src.Host
+	}
+	if src.Port != 0 {
+		dst.Port = src.Port
+	}
+	if src.User != "" {
+		dst.User = src.User
+	}
+	if src.SSHPass != "" {
+		dst.SSHPass = src.SSHPass
+	}
+	if src.BecomePass != "" {
+		dst.BecomePass = src.BecomePass
+	}
+	if len(src.PrivateKeys) > 0 {
+		dst.PrivateKeys = src.PrivateKeys
+	}
+}
+
+// varString converts scalar var value into string, non-scalar values are converted into empty string
+func varString(v any) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.TrimSpace(value)
+	case int, int64, uint64, float64, bool:
+		return fmt.Sprint(value)
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,206 @@
+package ansible
+
+import (
+	"fmt"
+	"net"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/etkecc/go-ansible"
+)
+
+// patternSubscript matches the subscript of the host pattern, e.g. webservers[0], webservers[-1], webservers[0:2], webservers[1:]
+var patternSubscript = regexp.MustCompile(`^(.+)\[(?:(-?[0-9]+)|([0-9]+)[:-]([0-9]*))\]$`)
+
+// matchPattern returns the names of the hosts matched by the ansible host pattern, e.g.
+// "web*", "~web\d+", "group", "group:&prod", "group:!canary", "webservers[0]", "db,web[-1]".
+// Same as ansible, regular patterns are evaluated first, then intersections (&), then exclusions (!)
+func matchPattern(inv *ansible.Inventory, pattern string) ([]string, error) {
+	var regular, intersect, exclude []string
+	for _, term := range splitPattern(pattern) {
+		switch term[0] {
+		case '&':
+			intersect = append(intersect, term[1:])
+		case '!':
+			exclude = append(exclude, term[1:])
+		default:
+			regular = append(regular, term)
+		}
+	}
+	if len(regular) == 0 {
+		regular = []string{allGroup}
+	}
+
+	names := []string{}
+	for _, term := range regular {
+		matched, err := matchTerm(inv, term)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range matched {
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	for _, term := range intersect {
+		matched, err := matchTerm(inv, term)
+		if err != nil {
+			return nil, err
+		}
+		names = slices.DeleteFunc(names, func(name string) bool { return !slices.Contains(matched, name) })
+	}
+	for _, term := range exclude {
+		matched, err := matchTerm(inv, term)
+		if err != nil {
+			return nil, err
+		}
+		names = slices.DeleteFunc(names, func(name string) bool { return slices.Contains(matched, name) })
+	}
+
+	return names, nil
+}
+
+// splitPattern splits the pattern by commas, or by colons (outside of brackets) if there are no commas
+func splitPattern(pattern string) []string {
+	var parts []string
+	switch {
+	case strings.Contains(pattern, ","):
+		parts = strings.Split(pattern, ",")
+	case net.ParseIP(pattern) != nil: // IPv6 address
+		parts = []string{pattern}
+	default:
+		var depth, start int
+		for i, r := range pattern {
+			switch r {
+			case '[':
+				depth++
+			case ']':
+				depth--
+			case ':':
+				if depth == 0 {
+					parts = append(parts, pattern[start:i])
+					start = i + 1
+				}
+			}
+		}
+		parts = append(parts, pattern[start:])
+	}
+
+	terms := []string{}
+	for _, part := range parts {
+		if part = strings.TrimSpace(part); part != "" {
+			terms = append(terms, part)
+		}
+	}
+	return terms
+}
+
+// matchTerm returns the names of the hosts matched by the single pattern term:
+// hosts of the matching groups, and the matching hosts if no groups matched or the term is a glob or regex
+func matchTerm(inv *ansible.Inventory, term string) ([]string, error) {
+	term, subscript := splitSubscript(term)
+	match, err := termMatcher(term)
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	add := func(name string) {
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+
+	groups := make([]string, 0, len(inv.Groups))
+	for group := range inv.Groups {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	var groupMatched bool
+	for _, group := range groups {
+		if !match(group) {
+			continue
+		}
+		groupMatched = true
+		for _, host := range inv.Groups[group] {
+			add(host.Name)
+		}
+	}
+	if !groupMatched || strings.HasPrefix(term, "~") || strings.ContainsAny(term, ".?*[") {
+		for _, host := range inv.Groups[allGroup] {
+			if match(host.Name) {
+				add(host.Name)
+			}
+		}
+	}
+
+	return applySubscript(names, subscript), nil
+}
+
+// termMatcher returns the match function of the pattern term: regex (~ prefix) or glob
+func termMatcher(term string) (func(string) bool, error) {
+	if strings.HasPrefix(term, "~") {
+		re, err := regexp.Compile("^(?:" + term[1:] + ")")
+		if err != nil {
+			return nil, fmt.Errorf("invalid host pattern %q: %w", term, err)
+		}
+		return re.MatchString, nil
+	}
+	if _, err := path.Match(term, ""); err != nil {
+		return nil, fmt.Errorf("invalid host pattern %q: %w", term, err)
+	}
+	return func(name string) bool {
+		matched, _ := path.Match(term, name) //nolint:errcheck // the pattern is validated above
+		return matched
+	}, nil
+}
+
+// splitSubscript splits the term into the pattern and the subscript (without brackets), regex terms do not have subscripts
+func splitSubscript(term string) (pattern, subscript string) {
+	if strings.HasPrefix(term, "~") {
+		return term, ""
+	}
+	match := patternSubscript.FindStringSubmatch(term)
+	if match == nil {
+		return term, ""
+	}
+	if match[2] != "" {
+		return match[1], match[2]
+	}
+	return match[1], match[3] + ":" + match[4]
+}
+
+// applySubscript returns the subset of the names selected by the subscript: "N", "-N", "N:M" (inclusive), "N:"
+func applySubscript(names []string, subscript string) []string {
+	if subscript == "" {
+		return names
+	}
+	startStr, endStr, isRange := strings.Cut(subscript, ":")
+	start, _ := strconv.Atoi(startStr) //nolint:errcheck // validated by the regex
+	if !isRange {
+		if start < 0 {
+			start += len(names)
+		}
+		if start < 0 || start >= len(names) {
+			return []string{}
+		}
+		return names[start : start+1]
+	}
+
+	end := len(names) - 1
+	if endStr != "" {
+		end, _ = strconv.Atoi(endStr) //nolint:errcheck // validated by the regex
+	}
+	if end >= len(names) {
+		end = len(names) - 1
+	}
+	if start > end {
+		return []string{}
+	}
+	return names[start : end+1]
+}
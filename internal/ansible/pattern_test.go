@@ -0,0 +1,109 @@
+package ansible
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/etkecc/go-ansible"
+)
+
+const patternInventory = `[web]
+web01
+web02
+web03
+web04
+
+[db]
+db01
+
+[prod]
+web01
+web02
+db01
+
+[canary]
+web02
+
+[legacy]
+db
+`
+
+func TestMatchPattern(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"hosts": patternInventory})
+	inv, err := parseINIHostsFile(filepath.Join(dir, "hosts"), &ansible.Host{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		pattern  string
+		expected []string
+		err      bool
+	}{
+		{"host", "web02", []string{"web02"}, false},
+		{"group", "web", []string{"web01", "web02", "web03", "web04"}, false},
+		{"all", "all", []string{"web01", "web02", "web03", "web04", "db01", "db"}, false},
+		{"glob", "web0[^34]", []string{"web01", "web02"}, false},
+		{"numeric brackets are the subscript", "web0[12]", []string{}, false},
+		{"glob star", "web*", []string{"web01", "web02", "web03", "web04"}, false},
+		{"regex", "~web0[34]", []string{"web03", "web04"}, false},
+		{"regex anchored to the start", "~eb", []string{}, false},
+		{"union by commas", "db01,web04", []string{"db01", "web04"}, false},
+		{"union by colons", "db:canary", []string{"db01", "web02"}, false},
+		{"intersection", "web:&prod", []string{"web01", "web02"}, false},
+		{"exclusion", "web:!canary", []string{"web01", "web03", "web04"}, false},
+		{"intersection and exclusion", "web:&prod:!canary", []string{"web01"}, false},
+		{"exclusion only", "!web", []string{"db01", "db"}, false},
+		{"exclusion first", "!canary,prod", []string{"web01", "db01"}, false},
+		{"subscript", "web[0]", []string{"web01"}, false},
+		{"negative subscript", "web[-1]", []string{"web04"}, false},
+		{"range subscript", "web[1:3]", []string{"web02", "web03", "web04"}, false},
+		{"dash range subscript", "web[1-2]", []string{"web02", "web03"}, false},
+		{"open range subscript", "web[2:]", []string{"web03", "web04"}, false},
+		{"out of range subscript", "web[10]", []string{}, false},
+		{"subscript within the union", "db,web[-1]", []string{"db01", "web04"}, false},
+		{"groups before hosts", "db", []string{"db01"}, false},
+		{"glob matches groups and hosts", "d?", []string{"db01", "db"}, false},
+		{"ipv6", "::1", []string{}, false},
+		{"not found", "mail", []string{}, false},
+		{"invalid regex", "~web(", nil, true},
+		{"invalid glob", "web[", nil, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			names, err := matchPattern(inv, test.pattern)
+			if (err != nil) != test.err {
+				t.Fatalf("expected error: %t, got %v", test.err, err)
+			}
+			if !slices.Equal(names, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, names)
+			}
+		})
+	}
+}
+
+func TestSplitPattern(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		expected []string
+	}{
+		{"web", []string{"web"}},
+		{"web:db", []string{"web", "db"}},
+		{"web:&prod:!canary", []string{"web", "&prod", "!canary"}},
+		{"web, db ,", []string{"web", "db"}},
+		{"web[1:3]:db", []string{"web[1:3]", "db"}},
+		{"host:2222,db", []string{"host:2222", "db"}},
+		{"2001:db8::1", []string{"2001:db8::1"}},
+		{"", []string{}},
+	}
+	for _, test := range tests {
+		t.Run(test.pattern, func(t *testing.T) {
+			if terms := splitPattern(test.pattern); !slices.Equal(terms, test.expected) {
+				t.Errorf("expected %q, got %q", test.expected, terms)
+			}
+		})
+	}
+}
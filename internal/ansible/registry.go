@@ -0,0 +1,124 @@
+package ansible
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/etkecc/ansible-ssh/internal/config"
+	"github.com/etkecc/ansible-ssh/internal/logger"
+	"github.com/etkecc/go-ansible"
+)
+
+// source is an inventory source: the project dir, its ansible.cfg and the inventory path
+type source struct {
+	root       string
+	ansibleCfg string
+	path       string
+}
+
+// localSource returns the inventory source of the project within the current dir
+func localSource(cfg *config.Config) source {
+	root := projectDir(cfg.Path)
+	return source{root: root, ansibleCfg: findAnsibleCfg(root), path: cfg.Path}
+}
+
+// registrySources returns the inventory sources from the inventories config option (globs are expanded):
+// a dir is treated as a project dir (with its own ansible.cfg and the configured inventory path), a file - as an inventory file
+func registrySources(cfg *config.Config) []source {
+	sources := []source{}
+	for _, pattern := range cfg.Inventories {
+		matches, err := filepath.Glob(expandHome(pattern))
+		if err != nil {
+			logger.Println("cannot expand", pattern, "error:", err)
+			continue
+		}
+		if len(matches) == 0 {
+			logger.Debug("inventories:", pattern, "does not match anything")
+		}
+		for _, match := range matches {
+			if abs, err := filepath.Abs(match); err == nil {
+				match = abs
+			}
+			info, err := os.Stat(match)
+			if err != nil {
+				continue
+			}
+			src := source{root: filepath.Dir(match), path: match}
+			if info.IsDir() {
+				src = source{root: match, path: cfg.Path}
+			}
+			if local := filepath.Join(src.root, "ansible.cfg"); isFile(local) {
+				src.ansibleCfg = local
+			}
+			if !slices.Contains(sources, src) {
+				sources = append(sources, src)
+			}
+		}
+	}
+	return sources
+}
+
+// parse parses the inventory source
+func (s source) parse(cfg *config.Config, limit string) *ansible.Inventory {
+	return ParseInventory(s.config(cfg), s.root, s.ansibleCfg, limit)
+}
+
+// config returns the config with the inventory path of the source
+func (s source) config(cfg *config.Config) *config.Config {
+	srcCfg := *cfg
+	srcCfg.Path = s.path
+	return &srcCfg
+}
+
+// inventories are the inventory sources loaded within a single lookup: every source is parsed
+// (and its inventory scripts and vault password files are run) once, no matter how many times the hosts are matched
+type inventories struct {
+	cfg    *config.Config
+	loaded map[source]*loadedInventory
+}
+
+// newInventories returns the empty inventories cache
+func newInventories(cfg *config.Config) *inventories {
+	return &inventories{cfg: cfg, loaded: map[source]*loadedInventory{}}
+}
+
+// load returns the loaded inventory source, nil if it has no hosts
+func (i *inventories) load(src source) *loadedInventory {
+	if l, ok := i.loaded[src]; ok {
+		return l
+	}
+	l := loadInventory(src.config(i.cfg), src.root, src.ansibleCfg)
+	i.loaded[src] = l
+	return l
+}
+
+// owner returns the loaded source that defines the host
+func (i *inventories) owner(host *ansible.Host) (source, *loadedInventory, bool) {
+	for src, l := range i.loaded {
+		if l != nil && l.inv.Hosts[host.Name] == host {
+			return src, l, true
+		}
+	}
+	return source{}, nil, false
+}
+
+// findHosts returns the hosts matched by the pattern within all sources,
+// the same host found via several sources (e.g. the current project is in the registry as well) is returned once
+func findHosts(cfg *config.Config, sources []source, pattern string) []*ansible.Host {
+	hosts := []*ansible.Host{}
+	seen := map[string]bool{}
+	for _, src := range sources {
+		inv := src.parse(cfg, pattern)
+		if inv == nil {
+			continue
+		}
+		for _, host := range inv.Groups[allGroup] {
+			if id := HostID(host); !seen[id] {
+				seen[id] = true
+				hosts = append(hosts, host)
+			}
+		}
+	}
+	return hosts
+}
@@ -0,0 +1,214 @@
+package ansible
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/adrg/xdg"
+
+	"github.com/etkecc/ansible-ssh/internal/config"
+	"github.com/etkecc/ansible-ssh/internal/logger"
+	"github.com/etkecc/go-ansible"
+)
+
+const (
+	// defaultScriptTimeout is used when the inventory script timeout is not configured
+	defaultScriptTimeout = 30 * time.Second
+	// scriptWaitDelay is the time to wait for the script output pipes to close after the script is killed by timeout,
+	// e.g. a child process of the script keeps them open
+	scriptWaitDelay = time.Second
+	// metaKey is the dynamic inventory key that contains host vars
+	metaKey = "_meta"
+)
+
+// scriptGroup is a group within the dynamic inventory script output
+type scriptGroup struct {
+	Hosts    []string       `json:"hosts"`
+	Vars     map[string]any `json:"vars"`
+	Children []string       `json:"children"`
+}
+
+// scriptMeta is the _meta key of the dynamic inventory script output
+type scriptMeta struct {
+	HostVars map[string]map[string]any `json:"hostvars"`
+}
+
+// isScript returns true if the inventory source is an executable file
+func isScript(invPath string) bool {
+	info, err := os.Stat(invPath)
+	if err != nil {
+		return false
+	}
+	return info.Mode().IsRegular() && info.Mode().Perm()&0o111 != 0
+}
+
+// isInventoryScript returns true if the inventory source is an executable script (starts with the shebang) or binary,
+// so the static inventories with the executable bit (e.g. copied from a filesystem without permissions) are not run
+func isInventoryScript(invPath string) bool {
+	if !isScript(invPath) {
+		return false
+	}
+	fh, err := os.Open(invPath)
+	if err != nil {
+		return false
+	}
+	defer fh.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(fh, head)
+	if err != nil && n == 0 {
+		return false
+	}
+	head = head[:n]
+	return bytes.HasPrefix(head, []byte("#!")) || bytes.IndexByte(head, 0) != -1
+}
+
+// newScriptParser returns the dynamic inventory script parser
+func newScriptParser(cfg *config.Script) inventoryParser {
+	return func(f string, defaults *ansible.Host) (*ansible.Inventory, error) {
+		output, err := runScript(cfg, f)
+		if err != nil {
+			return nil, err
+		}
+		tree, err := parseScriptOutput(output)
+		if err != nil {
+			return nil, err
+		}
+		return tree.inventory(defaults), nil
+	}
+}
+
+// runScript runs the dynamic inventory script with --list, or returns its cached output
+func runScript(cfg *config.Script, script string) ([]byte, error) {
+	cachePath := scriptCachePath(script)
+	if output := readScriptCache(cachePath, cfg.CacheTTL); output != nil {
+		logger.Debug("using cached output of", script, "from", cachePath)
+		return output, nil
+	}
+
+	timeout := defaultScriptTimeout
+	if cfg.Timeout > 0 {
+		timeout = time.Duration(cfg.Timeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, script, "--list")
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.WaitDelay = scriptWaitDelay
+	logger.Debug("running inventory script", script, "--list")
+	if err := cmd.Run(); err != nil {
+		logger.Debug("inventory script", script, "stderr:", stderr.String())
+		return nil, err
+	}
+
+	output := stdout.Bytes()
+	writeScriptCache(cachePath, cfg.CacheTTL, output)
+	return output, nil
+}
+
+// parseScriptOutput parses the dynamic inventory JSON output:
+// groups with hosts, vars and children (or just lists of hosts), and host vars within the _meta.hostvars
+func parseScriptOutput(output []byte) (*inventoryTree, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(output, &raw); err != nil {
+		return nil, err
+	}
+
+	// JSON object keys order is lost, so groups are sorted by name to keep the hosts order stable
+	names := make([]string, 0, len(raw))
+	for name := range raw {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tree := newInventoryTree()
+	var meta scriptMeta
+	for _, name := range names {
+		data := raw[name]
+		if name == metaKey {
+			if err := json.Unmarshal(data, &meta); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		var group scriptGroup
+		if err := json.Unmarshal(data, &group); err != nil {
+			// shorthand form: "group": ["host1", "host2"]
+			if err := json.Unmarshal(data, &group.Hosts); err != nil {
+				return nil, err
+			}
+		}
+		tree.addGroup(name, "")
+		tree.addGroupVars(name, group.Vars)
+		for _, host := range group.Hosts {
+			tree.addHost(host, name, nil)
+		}
+		for _, child := range group.Children {
+			tree.addGroup(child, name)
+		}
+	}
+
+	tree.linkTopGroups()
+	for host, vars := range meta.HostVars {
+		tree.addHost(host, "", vars)
+	}
+
+	return tree, nil
+}
+
+// scriptCachePath returns the cache file path of the inventory script
+func scriptCachePath(script string) string {
+	if abs, err := filepath.Abs(script); err == nil {
+		script = abs
+	}
+	hash := sha256.Sum256([]byte(script))
+	return filepath.Join(xdg.CacheHome, "ansible-ssh", "inventory-"+hex.EncodeToString(hash[:8])+".json")
+}
+
+// readScriptCache returns the cached script output if it's not older than ttl seconds
+func readScriptCache(cachePath string, ttl int) []byte {
+	if ttl <= 0 {
+		return nil
+	}
+	info, err := os.Stat(cachePath)
+	if err != nil || time.Since(info.ModTime()) > time.Duration(ttl)*time.Second {
+		return nil
+	}
+	output, err := os.ReadFile(cachePath)
+	if err != nil {
+		return nil
+	}
+	return output
+}
+
+// writeScriptCache stores the script output in the cache
+func writeScriptCache(cachePath string, ttl int, output []byte) {
+	if ttl <= 0 {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0o700); err != nil {
+		logger.Debug("cannot create cache dir:", err)
+		return
+	}
+	tmp := cachePath + ".tmp"
+	if err := os.WriteFile(tmp, output, 0o600); err != nil {
+		logger.Debug("cannot write cache file:", err)
+		return
+	}
+	if err := os.Rename(tmp, cachePath); err != nil {
+		logger.Debug("cannot write cache file:", err)
+	}
+}
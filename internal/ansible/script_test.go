@@ -0,0 +1,231 @@
+package ansible
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adrg/xdg"
+
+	"github.com/etkecc/ansible-ssh/internal/config"
+	"github.com/etkecc/go-ansible"
+)
+
+// scriptOutput is the --list output of the test inventory script
+const scriptOutput = `{
+  "web": {"hosts": ["web01", "web02"], "vars": {"ansible_user": "deploy"}, "children": ["canary"]},
+  "canary": ["web03"],
+  "_meta": {"hostvars": {"web01": {"ansible_host": "10.0.0.1", "ansible_port": 2222}}}
+}`
+
+// writeScript creates the executable inventory script that records every run into the runs file,
+// requires the --list argument, and runs the body
+func writeScript(t *testing.T, dir, body string) (script, runs string) {
+	t.Helper()
+	script = filepath.Join(dir, "inventory.sh")
+	runs = filepath.Join(dir, "runs")
+	content := "#!/bin/sh\necho run >> '" + runs + "'\n[ \"$1\" = \"--list\" ] || exit 2\n" + body + "\n"
+	if err := os.WriteFile(script, []byte(content), 0o700); err != nil { //nolint:gosec // the script must be executable
+		t.Fatal(err)
+	}
+	return script, runs
+}
+
+// tempCacheHome points the cache dir to the temp dir until the end of the test
+func tempCacheHome(t *testing.T) {
+	t.Helper()
+	cacheHome := xdg.CacheHome
+	xdg.CacheHome = t.TempDir()
+	t.Cleanup(func() { xdg.CacheHome = cacheHome })
+}
+
+// countRuns returns the number of the script runs
+func countRuns(t *testing.T, runs string) int {
+	t.Helper()
+	data, err := os.ReadFile(runs)
+	if err != nil {
+		return 0
+	}
+	return strings.Count(string(data), "run\n")
+}
+
+func TestScriptParser(t *testing.T) {
+	tempCacheHome(t)
+	dir := t.TempDir()
+	script, _ := writeScript(t, dir, "cat <<'EOF'\n"+scriptOutput+"\nEOF")
+	if !isScript(script) {
+		t.Fatal("the script is not detected as executable")
+	}
+
+	inv, err := newScriptParser(&config.Script{})(script, &ansible.Host{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		host   string
+		port   int
+		user   string
+		groups []string
+	}{
+		{"web01", "10.0.0.1", 2222, "deploy", []string{"web"}},
+		{"web02", "web02", 0, "deploy", []string{"web"}},
+		{"web03", "web03", 0, "deploy", []string{"canary", "web"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			host := inv.Hosts[test.name]
+			if host == nil {
+				t.Fatal("host is not found")
+			}
+			if host.Host != test.host || host.Port != test.port || host.User != test.user {
+				t.Errorf("expected %s@%s:%d, got %s@%s:%d", test.user, test.host, test.port, host.User, host.Host, host.Port)
+			}
+			for _, group := range test.groups {
+				if !slices.Contains(host.Groups, group) {
+					t.Errorf("expected group %s within %v", group, host.Groups)
+				}
+			}
+		})
+	}
+}
+
+func TestDetectParserExecutable(t *testing.T) {
+	tempCacheHome(t)
+	tests := []struct {
+		name    string
+		content string
+		user    string
+	}{
+		{"script", "#!/bin/sh\necho '{\"web\": {\"hosts\": [\"web01\"], \"vars\": {\"ansible_user\": \"script\"}}}'\n", "script"},
+		{"ini without shebang", "[web]\nweb01 ansible_user=ini\n", "ini"},
+		{"yaml without shebang", "web:\n  hosts:\n    web01:\n      ansible_user: yaml\n", "yaml"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			invPath := filepath.Join(t.TempDir(), "hosts")
+			if err := os.WriteFile(invPath, []byte(test.content), 0o700); err != nil { //nolint:gosec // the script must be executable
+				t.Fatal(err)
+			}
+
+			inv, err := detectParser(&config.Config{}, nil, invPath)(invPath, &ansible.Host{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if host := inv.Hosts["web01"]; host == nil || host.User != test.user {
+				t.Errorf("expected web01 with user %q, got %+v", test.user, host)
+			}
+		})
+	}
+}
+
+func TestScriptParserErrors(t *testing.T) {
+	tempCacheHome(t)
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"exit code", "exit 1"},
+		{"invalid json", "echo '{not json'"},
+		{"invalid group", `echo '{"web": 42}'`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			script, _ := writeScript(t, t.TempDir(), test.body)
+			if _, err := newScriptParser(&config.Script{})(script, &ansible.Host{}); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestRunScriptCache(t *testing.T) {
+	tests := []struct {
+		name     string
+		ttl      int
+		age      time.Duration // age of the cache file before the second run
+		expected int           // expected number of the script runs
+	}{
+		{"disabled", 0, 0, 2},
+		{"fresh", 60, 0, 1},
+		{"expired", 60, 2 * time.Minute, 2},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tempCacheHome(t)
+			script, runs := writeScript(t, t.TempDir(), "echo '"+strings.ReplaceAll(scriptOutput, "\n", "")+"'")
+			cfg := &config.Script{CacheTTL: test.ttl}
+
+			first, err := runScript(cfg, script)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.age > 0 {
+				old := time.Now().Add(-test.age)
+				if err := os.Chtimes(scriptCachePath(script), old, old); err != nil {
+					t.Fatal(err)
+				}
+			}
+			second, err := runScript(cfg, script)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(first) != string(second) {
+				t.Errorf("expected the same output, got %q and %q", first, second)
+			}
+			if count := countRuns(t, runs); count != test.expected {
+				t.Errorf("expected %d runs, got %d", test.expected, count)
+			}
+			_, err = os.Stat(scriptCachePath(script))
+			if cached := err == nil; cached != (test.ttl > 0) {
+				t.Errorf("expected cache file: %t, got: %t", test.ttl > 0, cached)
+			}
+		})
+	}
+}
+
+func TestRunScriptTimeout(t *testing.T) {
+	tempCacheHome(t)
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"script", "exec sleep 30"},
+		{"child keeps the output open", "sleep 30\necho '{}'"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			script, _ := writeScript(t, t.TempDir(), test.body)
+			start := time.Now()
+			_, err := runScript(&config.Script{Timeout: 1, CacheTTL: 60}, script)
+			if err == nil {
+				t.Fatal("expected the timeout error")
+			}
+			if elapsed := time.Since(start); elapsed > 1*time.Second+scriptWaitDelay+2*time.Second {
+				t.Errorf("expected the script to be killed after 1s, took %s", elapsed)
+			}
+			if _, err := os.Stat(scriptCachePath(script)); err == nil {
+				t.Error("the failed run must not be cached")
+			}
+		})
+	}
+}
+
+func TestReadScriptCache(t *testing.T) {
+	dir := t.TempDir()
+	cachePath := filepath.Join(dir, "cache.json")
+	writeScriptCache(cachePath, 10, []byte("{}"))
+	for _, ttl := range []int{-1, 0, 10} {
+		t.Run(strconv.Itoa(ttl), func(t *testing.T) {
+			output := readScriptCache(cachePath, ttl)
+			if (output != nil) != (ttl > 0) {
+				t.Errorf("expected cached output with ttl %d: %t, got %q", ttl, ttl > 0, output)
+			}
+		})
+	}
+}
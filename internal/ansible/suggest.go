@@ -0,0 +1,188 @@
+package ansible
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/etkecc/ansible-ssh/internal/logger"
+	"github.com/etkecc/ansible-ssh/internal/prompt"
+	"github.com/etkecc/go-ansible"
+)
+
+const (
+	// minSuggestScore is the minimal similarity score of the suggestion
+	minSuggestScore = 0.5
+	// defaultSuggestLimit is used when the suggestions limit is not configured
+	defaultSuggestLimit = 5
+)
+
+// suggestion is a host or a group similar to the requested one
+type suggestion struct {
+	name  string        // host or group name
+	match string        // the matched value: host name, address or group name
+	score float64       // similarity score, 0..1
+	host  *ansible.Host // nil for groups
+}
+
+// String returns the human-readable suggestion
+func (s suggestion) String() string {
+	switch {
+	case s.host == nil:
+		return s.name + " (group)"
+	case s.match != s.name:
+		return s.name + " (" + s.match + ")"
+	default:
+		return s.name
+	}
+}
+
+// suggestHost looks for the hosts and groups similar to the input (e.g. typos) within all inventories, reusing the already loaded ones.
+// If exactly one suggestion clears the auto-connect threshold, the host is returned right away,
+// otherwise the suggestions are listed, and the user is asked to pick one (if enabled and possible)
+func suggestHost(invs *inventories, input string) *ansible.Host {
+	cfg := invs.cfg
+	suggestions := suggest(invs, append([]source{localSource(cfg)}, registrySources(cfg)...), input)
+	if len(suggestions) == 0 {
+		return nil
+	}
+
+	if threshold := cfg.Suggest.AutoConnect; threshold > 0 {
+		var confident []suggestion
+		for _, s := range suggestions {
+			if s.score >= threshold {
+				confident = append(confident, s)
+			}
+		}
+		if len(confident) == 1 {
+			logger.Println("host", input, "not found, connecting to", confident[0].String())
+			return pickSuggestion(invs, confident[0])
+		}
+	}
+
+	items := make([]string, 0, len(suggestions)+1)
+	for _, s := range suggestions {
+		items = append(items, s.String())
+	}
+	if !(cfg.InventoryOnly || cfg.Suggest.Prompt) || !prompt.IsInteractive() {
+		logger.Println("host", input, "not found, did you mean:", strings.Join(items, ", "))
+		return nil
+	}
+	items = append(items, "none, run ssh "+input+" as is")
+	idx, err := prompt.Choose("host "+input+" not found, did you mean:", items)
+	if err != nil || idx == len(suggestions) {
+		return nil
+	}
+	return pickSuggestion(invs, suggestions[idx])
+}
+
+// pickSuggestion returns the suggested host, or resolves the suggested group
+func pickSuggestion(invs *inventories, s suggestion) *ansible.Host {
+	if s.host == nil {
+		return lookupHost(invs, s.name)
+	}
+	return withDefaults(invs.cfg, s.host)
+}
+
+// suggest returns the hosts and groups similar to the input within the sources, ranked by the similarity of
+// the host names, addresses (ansible_host) and group names
+func suggest(invs *inventories, sources []source, input string) []suggestion {
+	best := map[string]suggestion{}
+	consider := func(key string, s suggestion) {
+		if s.score < minSuggestScore {
+			return
+		}
+		if prev, ok := best[key]; !ok || s.score > prev.score {
+			best[key] = s
+		}
+	}
+
+	for _, src := range sources {
+		l := invs.load(src)
+		if l == nil {
+			continue
+		}
+		names, _ := l.match("") //nolint:errcheck // the empty pattern cannot fail
+		for _, host := range l.resolve(names) {
+			key := "host:" + HostID(host)
+			consider(key, suggestion{name: host.Name, match: host.Name, score: similarity(input, host.Name), host: host})
+			consider(key, suggestion{name: host.Name, match: host.Host, score: similarity(input, host.Host), host: host})
+		}
+		for group := range l.inv.Groups {
+			if group != allGroup && group != ungroupedGroup {
+				consider("group:"+group, suggestion{name: group, match: group, score: similarity(input, group)})
+			}
+		}
+	}
+
+	suggestions := make([]suggestion, 0, len(best))
+	for _, s := range best {
+		suggestions = append(suggestions, s)
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].score != suggestions[j].score {
+			return suggestions[i].score > suggestions[j].score
+		}
+		return suggestions[i].String() < suggestions[j].String()
+	})
+
+	limit := invs.cfg.Suggest.Limit
+	if limit <= 0 {
+		limit = defaultSuggestLimit
+	}
+	if len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+	logger.Debug("suggestions for", input+":", fmt.Sprint(suggestions))
+	return suggestions
+}
+
+// similarity returns the similarity score (0..1) of the input and the candidate,
+// based on the prefix and substring matches and the edit distance
+func similarity(input, candidate string) float64 {
+	input, candidate = strings.ToLower(input), strings.ToLower(candidate)
+	if input == "" || candidate == "" {
+		return 0
+	}
+	if input == candidate {
+		return 1
+	}
+
+	// coverage is how much of the candidate is covered by the input
+	coverage := float64(len(input)) / float64(len(candidate))
+	var score float64
+	switch {
+	case strings.HasPrefix(candidate, input):
+		score = 0.7 + 0.25*coverage
+	case strings.Contains(candidate, input):
+		score = 0.6 + 0.25*coverage
+	}
+
+	a, b := []rune(input), []rune(candidate)
+	maxLen := max(len(a), len(b))
+	if distScore := 1 - float64(levenshtein(a, b))/float64(maxLen); distScore > score {
+		score = distScore
+	}
+	return score
+}
+
+// levenshtein returns the edit distance between a and b
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,40 @@
+package ansible
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/etkecc/ansible-ssh/internal/config"
+)
+
+func TestSuggest(t *testing.T) {
+	t.Setenv("ANSIBLE_INVENTORY", "")
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"hosts": "[matrix]\nmatrix-prod ansible_host=203.0.113.7\nmatrix-staging\n[web]\nweb01\n",
+	})
+	invs := newInventories(&config.Config{Suggest: config.Suggest{Limit: 2}})
+	sources := []source{{root: root, path: "hosts"}}
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"matrx-prod", []string{"matrix-prod"}},
+		{"wbe01", []string{"web01"}},
+		{"203.0.113.8", []string{"matrix-prod (203.0.113.7)"}},
+		{"matri", []string{"matrix (group)", "matrix-prod"}},
+		{"unrelated", []string{}},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			suggestions := suggest(invs, sources, test.input)
+			names := []string{}
+			for _, s := range suggestions {
+				names = append(names, s.String())
+			}
+			if !slices.Equal(names, test.expected) {
+				t.Errorf("expected %q, got %q", test.expected, names)
+			}
+		})
+	}
+}
@@ -0,0 +1,490 @@
+package ansible
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/etkecc/ansible-ssh/internal/config"
+	"github.com/etkecc/ansible-ssh/internal/logger"
+	"github.com/etkecc/go-ansible"
+)
+
+// maxTemplateDepth limits the recursive template resolution (a var referencing a var referencing a var...)
+const maxTemplateDepth = 10
+
+var (
+	// ErrTemplateUndefined is returned when template references an undefined var
+	ErrTemplateUndefined = errors.New("undefined variable")
+	// ErrTemplateSyntax is returned when template cannot be parsed
+	ErrTemplateSyntax = errors.New("template syntax error")
+	// ErrTemplateDepth is returned when template recursion is too deep (probably, a loop)
+	ErrTemplateDepth = errors.New("template recursion is too deep")
+)
+
+// undefined is the value of undefined var, it's allowed as an input of the default filter only
+type undefined struct {
+	name string
+}
+
+// templateFilter is a jinja filter implementation
+type templateFilter func(value any, args []any) (any, error)
+
+var templateFilters = map[string]templateFilter{
+	"default": filterDefault,
+	"d":       filterDefault,
+	"lower": func(value any, _ []any) (any, error) {
+		return strings.ToLower(templateString(value)), nil
+	},
+	"upper": func(value any, _ []any) (any, error) {
+		return strings.ToUpper(templateString(value)), nil
+	},
+	"trim": func(value any, _ []any) (any, error) {
+		return strings.TrimSpace(templateString(value)), nil
+	},
+	"replace": func(value any, args []any) (any, error) {
+		if len(args) < 2 {
+			return nil, fmt.Errorf("%w: replace filter requires 2 arguments", ErrTemplateSyntax)
+		}
+		return strings.ReplaceAll(templateString(value), templateString(args[0]), templateString(args[1])), nil
+	},
+}
+
+// templater renders jinja-like templates ({{ var | filter(args) }}) using the provided vars
+type templater struct {
+	vars map[string]any
+}
+
+// newTemplater returns templater for the vars
+func newTemplater(vars map[string]any) *templater {
+	return &templater{vars: vars}
+}
+
+// Render renders all {{ expressions }} within the value
+func (t *templater) Render(value string) (string, error) {
+	return t.render(value, 0)
+}
+
+func (t *templater) render(value string, depth int) (string, error) {
+	if depth > maxTemplateDepth {
+		return "", ErrTemplateDepth
+	}
+
+	var out strings.Builder
+	rest := value
+	for {
+		start := strings.Index(rest, "{{")
+		if start == -1 {
+			out.WriteString(rest)
+			break
+		}
+		end := strings.Index(rest[start:], "}}")
+		if end == -1 {
+			return "", fmt.Errorf("%w: unclosed {{ in %q", ErrTemplateSyntax, value)
+		}
+		out.WriteString(rest[:start])
+
+		result, err := t.eval(rest[start+2:start+end], depth)
+		if err != nil {
+			return "", err
+		}
+		out.WriteString(templateString(result))
+		rest = rest[start+end+2:]
+	}
+
+	return out.String(), nil
+}
+
+// eval evaluates a single expression (without the {{ }} delimiters)
+func (t *templater) eval(expr string, depth int) (any, error) {
+	tokens, err := tokenizeTemplate(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &templateParser{t: t, tokens: tokens, depth: depth}
+	result, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("%w: unexpected %q in %q", ErrTemplateSyntax, p.tokens[p.pos].value, expr)
+	}
+	if u, ok := result.(undefined); ok {
+		return nil, fmt.Errorf("%w: %s", ErrTemplateUndefined, u.name)
+	}
+	return result, nil
+}
+
+// lookup returns the var value, string values are rendered recursively
+func (t *templater) lookup(name string, depth int) (any, error) {
+	value, ok := t.vars[name]
+	if !ok {
+		return undefined{name: name}, nil
+	}
+	return t.resolve(value, depth)
+}
+
+func (t *templater) resolve(value any, depth int) (any, error) {
+	str, ok := value.(string)
+	if !ok || !strings.Contains(str, "{{") {
+		return value, nil
+	}
+	return t.render(str, depth+1)
+}
+
+// templateTokenKind is the kind of the template expression token
+type templateTokenKind int
+
+const (
+	tokenIdent templateTokenKind = iota
+	tokenString
+	tokenNumber
+	tokenPunct
+)
+
+// templateToken is the template expression token
+type templateToken struct {
+	kind  templateTokenKind
+	value string
+}
+
+// tokenizeTemplate splits the expression into tokens: identifiers, quoted strings, numbers and punctuation (| ( ) , . [ ])
+func tokenizeTemplate(expr string) ([]templateToken, error) {
+	tokens := []templateToken{}
+	runes := []rune(expr)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '\'' || r == '"':
+			end := i + 1
+			var str strings.Builder
+			for ; end < len(runes) && runes[end] != r; end++ {
+				if runes[end] == '\\' && end+1 < len(runes) {
+					end++
+				}
+				str.WriteRune(runes[end])
+			}
+			if end >= len(runes) {
+				return nil, fmt.Errorf("%w: unclosed string in %q", ErrTemplateSyntax, expr)
+			}
+			tokens = append(tokens, templateToken{kind: tokenString, value: str.String()})
+			i = end + 1
+		case unicode.IsDigit(r) || (r == '-' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			end := i + 1
+			for end < len(runes) && (unicode.IsDigit(runes[end]) || runes[end] == '.') {
+				end++
+			}
+			tokens = append(tokens, templateToken{kind: tokenNumber, value: string(runes[i:end])})
+			i = end
+		case unicode.IsLetter(r) || r == '_':
+			end := i + 1
+			for end < len(runes) && (unicode.IsLetter(runes[end]) || unicode.IsDigit(runes[end]) || runes[end] == '_') {
+				end++
+			}
+			tokens = append(tokens, templateToken{kind: tokenIdent, value: string(runes[i:end])})
+			i = end
+		case strings.ContainsRune("|(),.[]", r):
+			tokens = append(tokens, templateToken{kind: tokenPunct, value: string(r)})
+			i++
+		default:
+			return nil, fmt.Errorf("%w: unsupported character %q in %q", ErrTemplateSyntax, r, expr)
+		}
+	}
+	return tokens, nil
+}
+
+// templateParser is a recursive descent parser (and evaluator) of the template expression:
+//
+//	expression = operand { "|" filter }
+//	filter     = ident [ "(" [ expression { "," expression } ] ")" ]
+//	operand    = string | number | ident { "." ident | "[" expression "]" }
+type templateParser struct {
+	t      *templater
+	tokens []templateToken
+	pos    int
+	depth  int
+}
+
+func (p *templateParser) peek(value string) bool {
+	return p.pos < len(p.tokens) && p.tokens[p.pos].kind == tokenPunct && p.tokens[p.pos].value == value
+}
+
+func (p *templateParser) next() (templateToken, error) {
+	if p.pos >= len(p.tokens) {
+		return templateToken{}, fmt.Errorf("%w: unexpected end of expression", ErrTemplateSyntax)
+	}
+	token := p.tokens[p.pos]
+	p.pos++
+	return token, nil
+}
+
+func (p *templateParser) expect(value string) error {
+	if !p.peek(value) {
+		return fmt.Errorf("%w: expected %q", ErrTemplateSyntax, value)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *templateParser) expression() (any, error) {
+	value, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek("|") {
+		p.pos++
+		if value, err = p.filter(value); err != nil {
+			return nil, err
+		}
+	}
+	return value, nil
+}
+
+func (p *templateParser) filter(value any) (any, error) {
+	name, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if name.kind != tokenIdent {
+		return nil, fmt.Errorf("%w: expected filter name, got %q", ErrTemplateSyntax, name.value)
+	}
+	filter, ok := templateFilters[name.value]
+	if !ok {
+		return nil, fmt.Errorf("%w: unsupported filter %q", ErrTemplateSyntax, name.value)
+	}
+
+	args := []any{}
+	if p.peek("(") {
+		p.pos++
+		for !p.peek(")") {
+			arg, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if !p.peek(",") {
+				break
+			}
+			p.pos++
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+	}
+
+	if u, ok := value.(undefined); ok && name.value != "default" && name.value != "d" {
+		return nil, fmt.Errorf("%w: %s", ErrTemplateUndefined, u.name)
+	}
+	return filter(value, args)
+}
+
+func (p *templateParser) operand() (any, error) {
+	token, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+
+	var value any
+	switch token.kind {
+	case tokenString:
+		return token.value, nil
+	case tokenNumber:
+		if i, err := strconv.Atoi(token.value); err == nil {
+			return i, nil
+		}
+		return strconv.ParseFloat(token.value, 64)
+	case tokenIdent:
+		switch token.value {
+		case "true", "True":
+			return true, nil
+		case "false", "False":
+			return false, nil
+		case "none", "None":
+			return nil, nil
+		}
+		if value, err = p.t.lookup(token.value, p.depth); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%w: unexpected %q", ErrTemplateSyntax, token.value)
+	}
+
+	return p.accessors(value)
+}
+
+// accessors evaluates .attr and [key] accessors
+func (p *templateParser) accessors(value any) (any, error) {
+	for p.peek(".") || p.peek("[") {
+		var key any
+		if p.peek(".") {
+			p.pos++
+			token, err := p.next()
+			if err != nil {
+				return nil, err
+			}
+			key = token.value
+		} else {
+			p.pos++
+			var err error
+			if key, err = p.expression(); err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+		}
+
+		var err error
+		if value, err = p.t.resolve(templateItem(value, key), p.depth); err != nil {
+			return nil, err
+		}
+	}
+	return value, nil
+}
+
+// templateItem returns the item of the map or list, or undefined
+func templateItem(value, key any) any {
+	switch container := value.(type) {
+	case map[string]any:
+		if item, ok := container[templateString(key)]; ok {
+			return item
+		}
+	case []any:
+		if idx, ok := key.(int); ok {
+			if idx < 0 {
+				idx += len(container)
+			}
+			if idx >= 0 && idx < len(container) {
+				return container[idx]
+			}
+		}
+	}
+	return undefined{name: fmt.Sprint(key)}
+}
+
+// filterDefault implements the default(value, boolean=false) filter
+func filterDefault(value any, args []any) (any, error) {
+	var fallback any = ""
+	if len(args) > 0 {
+		fallback = args[0]
+	}
+	if _, ok := value.(undefined); ok {
+		return fallback, nil
+	}
+	if len(args) > 1 && args[1] == true && templateString(value) == "" {
+		return fallback, nil
+	}
+	return value, nil
+}
+
+// templateString converts the value into string (python-like for lists)
+func templateString(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case undefined:
+		return ""
+	case bool:
+		if v {
+			return "True"
+		}
+		return "False"
+	case []any:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			if str, ok := item.(string); ok {
+				items = append(items, "'"+str+"'")
+				continue
+			}
+			items = append(items, templateString(item))
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// groupNames returns the group_names magic var value: sorted groups without "all"
+func groupNames(host *ansible.Host) []any {
+	groups := GroupNames(host)
+	names := make([]any, 0, len(groups))
+	for _, group := range groups {
+		names = append(names, group)
+	}
+	return names
+}
+
+// templateVars returns vars available to the host templates: host vars (already merged with group vars) and magic vars
+func templateVars(host *ansible.Host, playbookDir string) map[string]any {
+	vars := maps.Clone(host.Vars)
+	if vars == nil {
+		vars = map[string]any{}
+	}
+
+	short, _, _ := strings.Cut(host.Name, ".")
+	vars["inventory_hostname"] = host.Name
+	vars["inventory_hostname_short"] = short
+	vars["group_names"] = groupNames(host)
+	vars["playbook_dir"] = playbookDir
+	return vars
+}
+
+// renderHost renders templated connection fields of the host, fields that cannot be rendered are left as is (with a warning)
+func renderHost(host *ansible.Host, vars map[string]any) {
+	t := newTemplater(vars)
+	render := func(field, value string) string {
+		if !strings.Contains(value, "{{") {
+			return value
+		}
+		rendered, err := t.Render(value)
+		if err != nil {
+			logger.Warn("cannot render", field, "of", host.Name, "error:", err)
+			return value
+		}
+		return rendered
+	}
+
+	host.Host = render("ansible_host", host.Host)
+	host.User = render("ansible_user", host.User)
+	host.SSHPass = render("ansible_ssh_pass", host.SSHPass)
+	host.BecomePass = render("ansible_become_password", host.BecomePass)
+	for i, key := range host.PrivateKeys {
+		host.PrivateKeys[i] = render("ansible_ssh_private_key_file", key)
+	}
+	if port, err := strconv.Atoi(render("ansible_port", lastVar(vars, portVarNames))); err == nil && host.Port == 0 {
+		host.Port = port
+	}
+	for _, key := range extraVarNames {
+		if value, ok := host.Vars[key].(string); ok {
+			host.Vars[key] = render(key, value)
+		}
+	}
+}
+
+// playbookDir returns the playbook_dir magic var value: playbook_dir config option,
+// ANSIBLE_PLAYBOOK_DIR env var, ansible.cfg playbook_dir option, or the project root dir (relative values are resolved against it too)
+func playbookDir(cfg *config.Config, acfg *ansible.AnsibleCfg, root string) string {
+	dir := cfg.PlaybookDir
+	if dir == "" {
+		dir = os.Getenv("ANSIBLE_PLAYBOOK_DIR")
+	}
+	if dir == "" && acfg != nil {
+		dir = acfg.Config["defaults"]["playbook_dir"]
+	}
+	if dir == "" {
+		dir = root
+	}
+	if abs, err := filepath.Abs(anchorPath(root, dir)); err == nil {
+		dir = abs
+	}
+	return dir
+}
@@ -0,0 +1,154 @@
+package ansible
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/etkecc/ansible-ssh/internal/config"
+	"github.com/etkecc/go-ansible"
+)
+
+// writeFiles creates the files (relative path -> content) within the dir
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		fullPath := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fullPath, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestParseInventoryPlaybookDirKeyPath(t *testing.T) {
+	t.Setenv("ANSIBLE_PLAYBOOK_DIR", "")
+	t.Setenv("ANSIBLE_INVENTORY", "")
+	tests := []struct {
+		name        string
+		playbookDir string
+		expected    string // relative to the project root
+	}{
+		{"not configured", "", "../../inventory/host_vars/web01/sshkey"},
+		{"configured", "play/book", "inventory/host_vars/web01/sshkey"},
+		{"configured elsewhere", "play", "../inventory/host_vars/web01/sshkey"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeFiles(t, root, map[string]string{
+				"inventory/hosts":                    "[web]\nweb01 ansible_host=10.0.0.1\n",
+				"inventory/host_vars/web01/vars.yml": `ansible_ssh_private_key_file: "{{ playbook_dir }}/../../inventory/host_vars/{{ inventory_hostname }}/sshkey"` + "\n",
+			})
+			cfg := &config.Config{Path: "inventory/hosts"}
+			if test.playbookDir != "" {
+				cfg.PlaybookDir = filepath.Join(root, test.playbookDir)
+			}
+
+			inv := ParseInventory(cfg, root, "", "")
+			if inv == nil || inv.Hosts["web01"] == nil {
+				t.Fatal("web01 is not parsed")
+			}
+			keys := inv.Hosts["web01"].PrivateKeys
+			expected := filepath.Join(root, test.expected)
+			if len(keys) != 1 || filepath.Clean(keys[0]) != expected {
+				t.Errorf("expected private key %s, got %v", expected, keys)
+			}
+		})
+	}
+}
+
+func TestPlaybookDir(t *testing.T) {
+	tests := []struct {
+		name       string
+		cfg        string
+		env        string
+		ansibleCfg string
+		expected   string // relative to the project root
+	}{
+		{"default", "", "", "", "."},
+		{"config option", "playbooks", "env", "acfg", "playbooks"},
+		{"env var", "", "env", "acfg", "env"},
+		{"ansible.cfg", "", "", "acfg", "acfg"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root := t.TempDir()
+			t.Setenv("ANSIBLE_PLAYBOOK_DIR", test.env)
+			var acfg *ansible.AnsibleCfg
+			if test.ansibleCfg != "" {
+				acfg = &ansible.AnsibleCfg{Config: map[string]map[string]string{"defaults": {"playbook_dir": test.ansibleCfg}}}
+			}
+
+			if dir, expected := playbookDir(&config.Config{PlaybookDir: test.cfg}, acfg, root), filepath.Join(root, test.expected); dir != expected {
+				t.Errorf("expected %s, got %s", expected, dir)
+			}
+		})
+	}
+}
+
+func TestTemplaterRender(t *testing.T) {
+	host := &ansible.Host{
+		Name:   "web01.example.com",
+		Groups: []string{"web", "prod", allGroup},
+		Vars: ansible.HostVars{
+			"inventory_dir": "/project/inventory",
+			"server_ip":     "10.0.0.1",
+			"address":       "{{ server_ip }}",
+			"nested":        "{{ address }}",
+			"admin":         "  Admin  ",
+			"matrix":        map[string]any{"domain": "example.com", "ports": []any{22, 2222}},
+			"loop_a":        "{{ loop_b }}",
+			"loop_b":        "{{ loop_a }}",
+			"empty":         "",
+		},
+	}
+	vars := templateVars(host, "/project/play")
+	tests := []struct {
+		name     string
+		template string
+		expected string
+		err      error
+	}{
+		{"plain", "10.0.0.1", "10.0.0.1", nil},
+		{"lookup", "{{ server_ip }}", "10.0.0.1", nil},
+		{"lookup within text", "ssh://{{server_ip}}:22", "ssh://10.0.0.1:22", nil},
+		{"recursive", "{{ nested }}", "10.0.0.1", nil},
+		{"attribute", "{{ matrix.domain }}", "example.com", nil},
+		{"item", "{{ matrix['domain'] }}", "example.com", nil},
+		{"list index", "{{ matrix.ports[-1] }}", "2222", nil},
+		{"loop", "{{ loop_a }}", "", ErrTemplateDepth},
+		{"undefined", "{{ missing }}", "", ErrTemplateUndefined},
+		{"undefined attribute", "{{ matrix.missing }}", "", ErrTemplateUndefined},
+		{"undefined with filter", "{{ missing | lower }}", "", ErrTemplateUndefined},
+		{"default", "{{ missing | default('admin') }}", "admin", nil},
+		{"default of defined", "{{ server_ip | d('10.0.0.2') }}", "10.0.0.1", nil},
+		{"default of empty", "{{ empty | default('admin', true) }}", "admin", nil},
+		{"lower", "{{ admin | lower }}", "  admin  ", nil},
+		{"upper", "{{ admin | trim | upper }}", "ADMIN", nil},
+		{"replace", "{{ inventory_hostname | replace('.', '-') }}", "web01-example-com", nil},
+		{"trim", "{{ admin | trim }}", "Admin", nil},
+		{"inventory_hostname_short", "{{ inventory_hostname_short }}", "web01", nil},
+		{"group_names", "{{ group_names }}", "['prod', 'web']", nil},
+		{"group_names item", "{{ group_names[0] }}", "prod", nil},
+		{"inventory_dir", "{{ inventory_dir }}/host_vars/{{ inventory_hostname }}/sshkey", "/project/inventory/host_vars/web01.example.com/sshkey", nil},
+		{"playbook_dir", "{{ playbook_dir }}/../key", "/project/play/../key", nil},
+		{"unclosed", "{{ server_ip", "", ErrTemplateSyntax},
+		{"unsupported filter", "{{ server_ip | b64encode }}", "", ErrTemplateSyntax},
+		{"replace without arguments", "{{ server_ip | replace('.') }}", "", ErrTemplateSyntax},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rendered, err := newTemplater(vars).Render(test.template)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("expected error %v, got %v", test.err, err)
+			}
+			if rendered != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, rendered)
+			}
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package ansible
+
+import (
+	"maps"
+	"slices"
+	"sort"
+
+	"github.com/etkecc/go-ansible"
+)
+
+const (
+	allGroup       = "all"       // the implicit group that contains every host
+	ungroupedGroup = "ungrouped" // the implicit group of hosts without any other group
+)
+
+// inventoryTree is a flattened group tree with raw (not yet converted into hosts) vars,
+// it is used by the inventory parsers that support nested groups (YAML, dynamic inventory scripts)
+type inventoryTree struct {
+	groupVars   map[string]map[string]any // group vars by group name
+	groupTree   map[string][]string       // group children by group name
+	groupParent map[string][]string       // group parents by group name
+	hostVars    map[string]map[string]any // host vars by host name
+	hostGroups  map[string][]string       // direct host groups by host name
+	groupHosts  map[string][]string       // direct group hosts by group name, in the order of definition
+	groups      []string                  // group names in the order of definition
+	hosts       []string                  // host names in the order of definition
+}
+
+func newInventoryTree() *inventoryTree {
+	return &inventoryTree{
+		groupVars:   map[string]map[string]any{},
+		groupTree:   map[string][]string{},
+		groupParent: map[string][]string{},
+		hostVars:    map[string]map[string]any{},
+		hostGroups:  map[string][]string{},
+		groupHosts:  map[string][]string{},
+	}
+}
+
+// topParent returns the parent of the top-level group
+func topParent(group string) string {
+	if group == allGroup {
+		return ""
+	}
+	return allGroup
+}
+
+// addGroup adds the group to the tree, parent may be empty
+func (t *inventoryTree) addGroup(name, parent string) {
+	if _, ok := t.groupVars[name]; !ok {
+		t.groupVars[name] = map[string]any{}
+		t.groupTree[name] = []string{}
+		t.groups = append(t.groups, name)
+	}
+	if parent != "" && parent != name && !slices.Contains(t.groupParent[name], parent) {
+		t.groupParent[name] = append(t.groupParent[name], parent)
+		t.groupTree[parent] = append(t.groupTree[parent], name)
+	}
+}
+
+// addGroupVars adds vars to the group, existing vars are overwritten
+func (t *inventoryTree) addGroupVars(name string, vars map[string]any) {
+	if t.groupVars[name] == nil {
+		t.groupVars[name] = map[string]any{}
+	}
+	maps.Copy(t.groupVars[name], vars)
+}
+
+// addHost adds the host to the group (if not empty) and merges the host vars, existing vars are overwritten
+func (t *inventoryTree) addHost(name, group string, vars map[string]any) {
+	if _, ok := t.hostVars[name]; !ok {
+		t.hostVars[name] = map[string]any{}
+		t.hostGroups[name] = []string{}
+		t.hosts = append(t.hosts, name)
+	}
+	maps.Copy(t.hostVars[name], vars)
+	if group != "" && !slices.Contains(t.hostGroups[name], group) {
+		t.hostGroups[name] = append(t.hostGroups[name], group)
+		t.groupHosts[group] = append(t.groupHosts[group], name)
+	}
+}
+
+// linkTopGroups makes the groups without parents children of the "all" group
+func (t *inventoryTree) linkTopGroups() {
+	for _, name := range t.groups {
+		if len(t.groupParent[name]) == 0 {
+			t.addGroup(name, topParent(name))
+		}
+	}
+}
+
+// members returns the hosts of the group and all its child groups, the same order as in ansible:
+// the group's own hosts first, then hosts of the child groups (level by level), the "all" group follows the hosts definition order
+func (t *inventoryTree) members(group string) []string {
+	if group == allGroup {
+		return t.hosts
+	}
+	hosts := []string{}
+	seen := []string{}
+	queue := []string{group}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if slices.Contains(seen, current) {
+			continue
+		}
+		seen = append(seen, current)
+		for _, host := range t.groupHosts[current] {
+			if !slices.Contains(hosts, host) {
+				hosts = append(hosts, host)
+			}
+		}
+		queue = append(queue, t.groupTree[current]...)
+	}
+	return hosts
+}
+
+// ancestors returns the groups and all their parent groups
+func (t *inventoryTree) ancestors(groups []string) []string {
+	all := []string{}
+	queue := slices.Clone(groups)
+	for len(queue) > 0 {
+		group := queue[0]
+		queue = queue[1:]
+		if slices.Contains(all, group) {
+			continue
+		}
+		all = append(all, group)
+		queue = append(queue, t.groupParent[group]...)
+	}
+	return all
+}
+
+// depth returns the group depth within the group tree, the "all" group has depth 0
+func (t *inventoryTree) depth(group string, seen ...string) int {
+	var depth int
+	for _, parent := range t.groupParent[group] {
+		if slices.Contains(seen, parent) { // cycle protection
+			continue
+		}
+		if d := t.depth(parent, append(seen, group)...) + 1; d > depth {
+			depth = d
+		}
+	}
+	return depth
+}
+
+// mergedVars returns host vars merged with the vars of its groups, following the ansible precedence:
+// all group, parent groups, child groups (groups of the same depth are sorted by name), host
+func (t *inventoryTree) mergedVars(host string, groups []string) map[string]any {
+	sort.SliceStable(groups, func(i, j int) bool {
+		di, dj := t.depth(groups[i]), t.depth(groups[j])
+		if di != dj {
+			return di < dj
+		}
+		return groups[i] < groups[j]
+	})
+
+	vars := map[string]any{}
+	for _, group := range groups {
+		maps.Copy(vars, t.groupVars[group])
+	}
+	maps.Copy(vars, t.hostVars[host])
+	return vars
+}
+
+// inventory converts the tree into the inventory, hosts within the inventory groups keep the order of definition
+func (t *inventoryTree) inventory(defaults *ansible.Host) *ansible.Inventory {
+	inv := &ansible.Inventory{
+		Groups:    map[string][]*ansible.Host{},
+		GroupVars: map[string]map[string]string{},
+		GroupTree: t.groupTree,
+		Hosts:     map[string]*ansible.Host{},
+	}
+	for group, vars := range t.groupVars {
+		inv.Groups[group] = []*ansible.Host{}
+		inv.GroupVars[group] = map[string]string{}
+		for k, v := range vars {
+			if value := varString(v); value != "" {
+				inv.GroupVars[group][k] = value
+			}
+		}
+	}
+
+	for _, name := range t.hosts {
+		// the hosts defined within the "all" group directly are ungrouped, like in ansible
+		direct := slices.DeleteFunc(slices.Clone(t.hostGroups[name]), func(group string) bool { return group == allGroup })
+		if len(direct) == 0 {
+			direct = []string{ungroupedGroup}
+			t.groupHosts[ungroupedGroup] = append(t.groupHosts[ungroupedGroup], name)
+		}
+		groups := t.ancestors(append(slices.Clone(direct), allGroup))
+		// connection fields are calculated from the merged vars, but only the host's own vars are kept,
+		// group vars are available in the inventory GroupVars
+		host := hostFromVars(name, t.mergedVars(name, slices.Clone(groups)))
+		host.Vars = maps.Clone(t.hostVars[name])
+		if host.Host == "" {
+			host.Host = name
+		}
+		host.Group = slices.Min(direct) // the first group by name, so it does not depend on the order of the source
+		host.Groups = groups
+		host = ansible.MergeHost(host, defaults)
+
+		inv.Hosts[name] = host
+	}
+	for _, group := range append(slices.Clone(t.groups), allGroup, ungroupedGroup) {
+		if len(inv.Groups[group]) > 0 { // the "all" and "ungrouped" groups may be defined within the source explicitly
+			continue
+		}
+		for _, member := range t.members(group) {
+			inv.Groups[group] = append(inv.Groups[group], inv.Hosts[member])
+		}
+	}
+
+	return inv
+}
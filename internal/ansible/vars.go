@@ -0,0 +1,265 @@
+package ansible
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/etkecc/ansible-ssh/internal/logger"
+	"github.com/etkecc/go-ansible"
+)
+
+// varsExtensions are the extensions of group_vars and host_vars files, the same as in the ansible host_group_vars plugin
+var varsExtensions = []string{"", ".yml", ".yaml", ".json"}
+
+// varsLoader loads group_vars and host_vars files located next to the inventory sources,
+// and keeps the inventory files that define the inventory group and host vars (see Origins)
+type varsLoader struct {
+	dirs      []string
+	vault     *vault
+	fileVars  map[string]ansible.HostVars  // vars file path -> vars
+	groupVars map[string]map[string]string // group name -> var name -> inventory file
+	hostVars  map[string]inventoryHostVars // host name -> inventory host vars
+	quiet     bool                         // the files that cannot be parsed are not reported (see withoutVault)
+}
+
+// inventoryHostVars are the host's own vars defined within the inventory file
+type inventoryHostVars struct {
+	file string
+	vars ansible.HostVars
+}
+
+// varPlace is the place where the var has been defined: the inventory file (with the group or host name) or the vars file
+type varPlace struct {
+	file  string
+	group string // inventory group vars
+	host  string // inventory host vars
+}
+
+// newVarsLoader returns vars loader, the inventory sources are added with addInventory
+func newVarsLoader(v *vault) *varsLoader {
+	return &varsLoader{
+		vault:     v,
+		fileVars:  map[string]ansible.HostVars{},
+		groupVars: map[string]map[string]string{},
+		hostVars:  map[string]inventoryHostVars{},
+	}
+}
+
+// addInventory adds the parsed inventory source: its dir is used to look for the group_vars and host_vars files,
+// and its group and host vars are recorded, if a var is defined in several inventories, the first one wins (see mergeInventory)
+func (l *varsLoader) addInventory(invPath string, inv *ansible.Inventory) {
+	if dir := filepath.Dir(invPath); !slices.Contains(l.dirs, dir) {
+		l.dirs = append(l.dirs, dir)
+	}
+	for group, vars := range inv.GroupVars {
+		if l.groupVars[group] == nil {
+			l.groupVars[group] = map[string]string{}
+		}
+		for key := range vars {
+			if _, ok := l.groupVars[group][key]; !ok {
+				l.groupVars[group][key] = invPath
+			}
+		}
+	}
+	for name, host := range inv.Hosts {
+		if _, ok := l.hostVars[name]; !ok {
+			l.hostVars[name] = inventoryHostVars{file: invPath, vars: host.Vars}
+		}
+	}
+}
+
+// withoutVault returns the loader of the same inventory sources that does not decrypt the vault values
+// (they are replaced with the undecryptable placeholder) and does not report the files that cannot be parsed,
+// so the raw vars of many hosts can be checked cheaply
+func (l *varsLoader) withoutVault() *varsLoader {
+	return &varsLoader{
+		dirs:      l.dirs,
+		fileVars:  map[string]ansible.HostVars{},
+		quiet:     true,
+		groupVars: l.groupVars,
+		hostVars:  l.hostVars,
+	}
+}
+
+// HostVars returns all vars of the host, merged following the ansible precedence (see walk)
+func (l *varsLoader) HostVars(inv *ansible.Inventory, host *ansible.Host) ansible.HostVars {
+	vars := ansible.HostVars{}
+	l.walk(inv, host, func(key string, value any, _ varPlace) {
+		vars[key] = value
+	})
+	return vars
+}
+
+// Origins returns the origins of all vars of the host, from the lowest precedence to the highest (see walk)
+func (l *varsLoader) Origins(inv *ansible.Inventory, host *ansible.Host) map[string][]Origin {
+	origins := map[string][]Origin{}
+	l.walk(inv, host, func(key string, value any, place varPlace) {
+		origins[key] = append(origins[key], place.origin(key, varString(value)))
+	})
+	return origins
+}
+
+// walk calls the visit func for every var of the host following the ansible precedence, the later calls win:
+// inventory group vars, group_vars/all, group_vars/GROUP (parent groups first, then child groups),
+// inventory host vars, host_vars/NAME
+func (l *varsLoader) walk(inv *ansible.Inventory, host *ansible.Host, visit func(key string, value any, place varPlace)) {
+	groups := sortGroups(inv, append(slices.Clone(host.Groups), allGroup))
+	for _, group := range groups {
+		for k, v := range inv.GroupVars[group] {
+			visit(k, v, varPlace{file: l.groupVars[group][k], group: group})
+		}
+	}
+	for _, group := range groups {
+		l.walkFiles("group_vars", group, visit)
+	}
+	own := l.hostVars[host.Name]
+	for k, v := range own.vars {
+		visit(k, v, varPlace{file: own.file, host: host.Name})
+	}
+	l.walkFiles("host_vars", host.Name, visit)
+}
+
+// walkFiles calls the visit func for every var of the KIND/NAME files within all inventory dirs,
+// if a var is defined in several inventory dirs, the first one wins
+func (l *varsLoader) walkFiles(kind, name string, visit func(key string, value any, place varPlace)) {
+	for _, varsPath := range l.files(kind, name) {
+		for k, v := range l.read(varsPath) {
+			visit(k, v, varPlace{file: varsPath})
+		}
+	}
+}
+
+// read returns the vars of the file (once per file), empty vars if the file cannot be parsed
+func (l *varsLoader) read(varsPath string) ansible.HostVars {
+	if vars, ok := l.fileVars[varsPath]; ok {
+		return vars
+	}
+	vars, err := readYAMLVarsFile(varsPath, l.vault)
+	if err != nil {
+		if l.quiet {
+			logger.Debug("cannot parse", varsPath, "error:", err)
+		} else {
+			logger.Println("cannot parse", varsPath, "error:", err)
+		}
+		vars = ansible.HostVars{}
+	} else {
+		logger.Debug("loaded", varsPath)
+	}
+	l.fileVars[varsPath] = vars
+	return vars
+}
+
+// origin returns the origin of the var, with the line number if it can be found
+func (p varPlace) origin(key, value string) Origin {
+	switch {
+	case p.group != "":
+		return Origin{Value: value, Var: key, Source: fileLine(p.file, groupAnchor(p.group), key)}
+	case p.host != "":
+		src, hostPort := hostLine(p.file, p.host, key)
+		if hostPort {
+			key = "host:port"
+		}
+		return Origin{Value: value, Var: key, Source: src}
+	default:
+		return Origin{Value: value, Var: key, Source: fileLine(p.file, nil, key)}
+	}
+}
+
+// files returns all KIND/NAME files within all inventory dirs, in the order they are applied (the last one wins)
+func (l *varsLoader) files(kind, name string) []string {
+	files := []string{}
+	for i := len(l.dirs) - 1; i >= 0; i-- {
+		files = append(files, varsFiles(filepath.Join(l.dirs[i], kind), name)...)
+	}
+	return files
+}
+
+// varsFiles returns NAME, NAME.yml, NAME.yaml, NAME.json files within the dir,
+// and all files with the same extensions within the NAME dir (recursively, in lexical order)
+func varsFiles(dir, name string) []string {
+	files := []string{}
+	base := filepath.Join(dir, name)
+	for _, ext := range varsExtensions {
+		info, err := os.Stat(base + ext)
+		if err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				logger.Println("cannot read", base+ext, "error:", err)
+			}
+			continue
+		}
+		if !info.IsDir() {
+			files = append(files, base+ext)
+			continue
+		}
+
+		err = filepath.WalkDir(base+ext, func(itempath string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			hidden := strings.HasPrefix(entry.Name(), ".") && itempath != base+ext
+			if entry.IsDir() {
+				if hidden {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !hidden && slices.Contains(varsExtensions, filepath.Ext(itempath)) {
+				files = append(files, itempath)
+			}
+			return nil
+		})
+		if err != nil {
+			logger.Println("cannot read", base+ext, "error:", err)
+		}
+	}
+
+	return files
+}
+
+// sortGroups sorts the groups following the ansible precedence: the "all" group first,
+// then by the group depth within the group tree (parent groups first), groups of the same depth are sorted by name
+func sortGroups(inv *ansible.Inventory, groups []string) []string {
+	parents := map[string][]string{}
+	for parent, children := range inv.GroupTree {
+		for _, child := range children {
+			parents[child] = append(parents[child], parent)
+		}
+	}
+	var depth func(group string, seen []string) int
+	depth = func(group string, seen []string) int {
+		if group == allGroup {
+			return 0
+		}
+		maxDepth := 1
+		for _, parent := range parents[group] {
+			if slices.Contains(seen, parent) { // cycle protection
+				continue
+			}
+			if d := depth(parent, append(seen, group)) + 1; d > maxDepth {
+				maxDepth = d
+			}
+		}
+		return maxDepth
+	}
+
+	sorted := []string{}
+	depths := map[string]int{}
+	for _, group := range groups {
+		if !slices.Contains(sorted, group) {
+			sorted = append(sorted, group)
+			depths[group] = depth(group, nil)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if depths[sorted[i]] != depths[sorted[j]] {
+			return depths[sorted[i]] < depths[sorted[j]]
+		}
+		return sorted[i] < sorted[j]
+	})
+	return sorted
+}
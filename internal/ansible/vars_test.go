@@ -0,0 +1,94 @@
+package ansible
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/etkecc/ansible-ssh/internal/config"
+	"github.com/etkecc/go-ansible"
+)
+
+func TestVarsPrecedence(t *testing.T) {
+	t.Setenv("ANSIBLE_INVENTORY", "")
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"inventory/hosts.yml": `all:
+  vars:
+    inv_all: inv all
+    gv_all: inv all
+    inv_group: inv all
+  children:
+    parent:
+      vars:
+        inv_group: inv parent
+        inv_child: inv parent
+        gv_all_deep: inv parent
+      children:
+        child:
+          vars:
+            inv_child: inv child
+            inv_host: inv child
+          hosts:
+            web01:
+              inv_host: inv host
+              host_vars: inv host
+    aaa:
+      hosts:
+        web01:
+`,
+		"inventory/group_vars/all.yml":        "gv_all: gv all\ngv_all_deep: gv all\ngv_parent: gv all\n",
+		"inventory/group_vars/parent.yml":     "gv_parent: gv parent\ngv_child: gv parent\ngv_name: gv parent\n",
+		"inventory/group_vars/aaa.yml":        "gv_name: gv aaa\n",
+		"inventory/group_vars/child/vars.yml": "gv_child: gv child\ninv_host: gv child\n",
+		"inventory/host_vars/web01/vars.yml":  "host_vars: hv dir\nhv_file: hv dir\n",
+		"inventory/host_vars/web01.yml":       "hv_file: hv file\n",
+	})
+
+	inv := ParseInventory(&config.Config{Path: "inventory/hosts.yml"}, root, "", "")
+	if inv == nil || inv.Hosts["web01"] == nil {
+		t.Fatal("web01 is not parsed")
+	}
+	host := inv.Hosts["web01"]
+	if expected := []string{allGroup, "aaa", "parent", "child"}; !slices.Equal(sortGroups(inv, host.Groups), expected) {
+		t.Errorf("expected groups order %v, got %v", expected, sortGroups(inv, host.Groups))
+	}
+
+	tests := []struct {
+		key      string
+		expected string
+	}{
+		{"inv_all", "inv all"},      // the inventory all group vars only
+		{"inv_group", "inv parent"}, // the inventory group vars win over the inventory all vars
+		{"inv_child", "inv child"},  // the inventory child group vars win over the parent group vars
+		{"gv_all", "gv all"},        // group_vars/all wins over the inventory all vars
+		{"gv_all_deep", "gv all"},   // group_vars/all wins over the inventory vars of any group
+		{"gv_parent", "gv parent"},  // group_vars/GROUP wins over group_vars/all
+		{"gv_child", "gv child"},    // the child group wins over the parent group
+		{"gv_name", "gv parent"},    // the groups of the same depth are applied by name
+		{"inv_host", "inv host"},    // the inventory host vars win over group_vars
+		{"host_vars", "hv dir"},     // host_vars/HOST wins over the inventory host vars
+		{"hv_file", "hv file"},      // host_vars/HOST.yml is applied after the host_vars/HOST dir
+	}
+	for _, test := range tests {
+		t.Run(test.key, func(t *testing.T) {
+			if value := host.Vars.String(test.key); value != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, value)
+			}
+		})
+	}
+}
+
+func TestSortGroups(t *testing.T) {
+	inv := &ansible.Inventory{GroupTree: map[string][]string{
+		allGroup: {"web", "db", "loop"},
+		"web":    {"prod"},
+		"prod":   {"canary"},
+		"db":     {"prod"},
+		"loop":   {"cycle"},
+		"cycle":  {"loop"},
+	}}
+	expected := []string{allGroup, "db", "web", "cycle", "prod", "canary"} // cycle is a child of loop (depth 2), the cycle back to loop is ignored
+	if groups := sortGroups(inv, []string{"canary", "prod", "web", "db", allGroup, "web", "cycle"}); !slices.Equal(groups, expected) {
+		t.Errorf("expected %v, got %v", expected, groups)
+	}
+}
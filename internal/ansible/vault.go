@@ -0,0 +1,278 @@
+package ansible
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/etkecc/ansible-ssh/internal/config"
+	"github.com/etkecc/ansible-ssh/internal/logger"
+	"github.com/etkecc/go-ansible"
+)
+
+const (
+	vaultHeader     = "$ANSIBLE_VAULT"
+	vaultTag        = "!vault"
+	vaultIterations = 10000
+	vaultKeyLength  = 32
+	vaultIVLength   = 16
+	// undecryptable replaces the inline vault values that cannot be decrypted,
+	// hosts that use such values for the connection are skipped instead of connecting with the wrong credentials
+	undecryptable = "<cannot decrypt the vault value>"
+)
+
+var (
+	// ErrVaultNoPassword is returned when vault-encrypted data is found, but no vault password is configured
+	ErrVaultNoPassword = errors.New("vault password is not configured")
+	// ErrVaultFormat is returned when vault-encrypted data cannot be parsed
+	ErrVaultFormat = errors.New("invalid vault format")
+	// ErrVaultHMAC is returned when vault password is wrong or data is corrupted
+	ErrVaultHMAC = errors.New("vault HMAC mismatch (wrong password?)")
+)
+
+// vaultSecret is a vault password with an optional vault id label
+type vaultSecret struct {
+	label    string
+	password []byte
+}
+
+// vault decrypts ansible-vault encrypted data, passwords are loaded lazily on the first use
+type vault struct {
+	sources []vaultSource
+	secrets []vaultSecret
+	once    sync.Once
+}
+
+// vaultSource is a vault password file with an optional vault id label
+type vaultSource struct {
+	label string
+	path  string
+}
+
+// newVault returns vault with the password sources in the following order:
+// ANSIBLE_VAULT_PASSWORD_FILE env var, ansible.cfg vault_identity_list and vault_password_file, the vault_password_file config option
+func newVault(cfg *config.Config, acfg *ansible.AnsibleCfg) *vault {
+	v := &vault{}
+	v.addSource("", os.Getenv("ANSIBLE_VAULT_PASSWORD_FILE"))
+	if acfg != nil {
+		for _, identity := range strings.Split(acfg.Config["defaults"]["vault_identity_list"], ",") {
+			label, path, ok := strings.Cut(strings.TrimSpace(identity), "@")
+			if !ok {
+				label, path = "", label
+			}
+			v.addSource(label, path)
+		}
+		v.addSource("", acfg.Config["defaults"]["vault_password_file"])
+	}
+	v.addSource("", cfg.VaultPassFile)
+
+	return v
+}
+
+func (v *vault) addSource(label, path string) {
+	if path = strings.TrimSpace(path); path == "" {
+		return
+	}
+	v.sources = append(v.sources, vaultSource{label: label, path: expandHome(path)})
+}
+
+// load reads the vault passwords from files, or runs them if they are executable (like ansible does)
+func (v *vault) load() {
+	for _, source := range v.sources {
+		var password []byte
+		var err error
+		if isScript(source.path) {
+			password, err = exec.Command(source.path).Output() //nolint:gosec // that's intended
+		} else {
+			password, err = os.ReadFile(source.path)
+		}
+		if err != nil {
+			logger.Warn("cannot read vault password file", source.path, "error:", err)
+			continue
+		}
+		password = bytes.TrimSpace(password)
+		if len(password) == 0 {
+			continue
+		}
+		v.secrets = append(v.secrets, vaultSecret{label: source.label, password: password})
+	}
+}
+
+// Decrypt decrypts $ANSIBLE_VAULT;1.1;AES256 and $ANSIBLE_VAULT;1.2;AES256;label data,
+// secrets with the matching vault id label are tried first
+func (v *vault) Decrypt(data []byte) ([]byte, error) {
+	if v == nil {
+		return nil, ErrVaultNoPassword
+	}
+	v.once.Do(v.load)
+	if len(v.secrets) == 0 {
+		return nil, ErrVaultNoPassword
+	}
+
+	header, body, _ := bytes.Cut(bytes.TrimSpace(data), []byte("\n"))
+	parts := strings.Split(strings.TrimSpace(string(header)), ";")
+	if len(parts) < 3 || parts[0] != vaultHeader || parts[2] != "AES256" {
+		return nil, fmt.Errorf("%w: unsupported header %q", ErrVaultFormat, header)
+	}
+	var label string
+	if parts[1] == "1.2" && len(parts) > 3 {
+		label = parts[3]
+	}
+
+	salt, mac, ciphertext, err := parseVaultBody(body)
+	if err != nil {
+		return nil, err
+	}
+
+	var lastErr error
+	for _, secret := range v.orderedSecrets(label) {
+		plaintext, err := decryptVault(secret.password, salt, mac, ciphertext)
+		if err == nil {
+			return plaintext, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// orderedSecrets returns secrets with the matching label first
+func (v *vault) orderedSecrets(label string) []vaultSecret {
+	ordered := make([]vaultSecret, 0, len(v.secrets))
+	for _, secret := range v.secrets {
+		if label != "" && secret.label == label {
+			ordered = append(ordered, secret)
+		}
+	}
+	for _, secret := range v.secrets {
+		if label == "" || secret.label != label {
+			ordered = append(ordered, secret)
+		}
+	}
+	return ordered
+}
+
+// parseVaultBody parses hex-encoded vault body: hex(hex(salt) \n hex(hmac) \n hex(ciphertext))
+func parseVaultBody(body []byte) (salt, mac, ciphertext []byte, err error) {
+	encoded := strings.Join(strings.Fields(string(body)), "")
+	decoded, err := hex.DecodeString(encoded)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("%w: %w", ErrVaultFormat, err)
+	}
+	lines := strings.Split(string(decoded), "\n")
+	if len(lines) != 3 {
+		return nil, nil, nil, fmt.Errorf("%w: unexpected body", ErrVaultFormat)
+	}
+
+	fields := make([][]byte, 0, len(lines))
+	for _, line := range lines {
+		field, err := hex.DecodeString(strings.TrimSpace(line))
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("%w: %w", ErrVaultFormat, err)
+		}
+		fields = append(fields, field)
+	}
+	return fields[0], fields[1], fields[2], nil
+}
+
+// decryptVault derives the keys with PBKDF2-SHA256, verifies HMAC-SHA256 and decrypts AES256-CTR ciphertext
+func decryptVault(password, salt, mac, ciphertext []byte) ([]byte, error) {
+	keys := pbkdf2SHA256(password, salt, vaultIterations, 2*vaultKeyLength+vaultIVLength)
+	cipherKey, hmacKey, iv := keys[:vaultKeyLength], keys[vaultKeyLength:2*vaultKeyLength], keys[2*vaultKeyLength:]
+
+	hash := hmac.New(sha256.New, hmacKey)
+	hash.Write(ciphertext)
+	if !hmac.Equal(hash.Sum(nil), mac) {
+		return nil, ErrVaultHMAC
+	}
+
+	block, err := aes.NewCipher(cipherKey)
+	if err != nil {
+		return nil, err
+	}
+	plaintext := make([]byte, len(ciphertext))
+	cipher.NewCTR(block, iv).XORKeyStream(plaintext, ciphertext)
+
+	// PKCS#7 padding
+	if len(plaintext) == 0 {
+		return plaintext, nil
+	}
+	padding := int(plaintext[len(plaintext)-1])
+	if padding == 0 || padding > aes.BlockSize || padding > len(plaintext) {
+		return nil, fmt.Errorf("%w: invalid padding", ErrVaultFormat)
+	}
+	return plaintext[:len(plaintext)-padding], nil
+}
+
+// pbkdf2SHA256 implements PBKDF2 (RFC 8018) with HMAC-SHA256
+func pbkdf2SHA256(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	hashLen := prf.Size()
+	blocks := (keyLen + hashLen - 1) / hashLen
+
+	key := make([]byte, 0, blocks*hashLen)
+	u := make([]byte, hashLen)
+	for block := 1; block <= blocks; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write([]byte{byte(block >> 24), byte(block >> 16), byte(block >> 8), byte(block)})
+		u = prf.Sum(u[:0])
+		t := bytes.Clone(u)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:keyLen]
+}
+
+// isVaultEncrypted returns true if data is vault-encrypted
+func isVaultEncrypted(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(data), []byte(vaultHeader))
+}
+
+// undecryptableVars returns the ansible_* vars of the host that use the inline vault values that cannot be decrypted,
+// split into the vars used for the connection (address, user, password, keys, port and the ssh options),
+// and the other vars (e.g. the become password of another vault id)
+func undecryptableVars(host *ansible.Host) (connection, other []string) {
+	fields := map[string]string{
+		"ansible_host":                 host.Host,
+		"ansible_user":                 host.User,
+		"ansible_password":             host.SSHPass,
+		"ansible_ssh_private_key_file": strings.Join(host.PrivateKeys, " "),
+	}
+	for key, value := range fields {
+		if strings.Contains(value, undecryptable) {
+			connection = append(connection, key)
+		}
+	}
+	connectionVars := slices.Concat(hostVarNames, userVarNames, passVarNames, keyVarNames, portVarNames, extraVarNames)
+	for key := range host.Vars {
+		if !strings.HasPrefix(key, "ansible_") || !strings.Contains(host.Vars.String(key), undecryptable) || slices.Contains(connection, key) {
+			continue
+		}
+		if slices.Contains(connectionVars, key) {
+			connection = append(connection, key)
+		} else {
+			other = append(other, key)
+		}
+	}
+	sort.Strings(connection)
+	sort.Strings(other)
+	return connection, other
+}
@@ -0,0 +1,150 @@
+package ansible
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/etkecc/ansible-ssh/internal/config"
+	"github.com/etkecc/go-ansible"
+)
+
+// The vectors below follow the ansible-vault encrypt_string format (fixed salts, so they are reproducible):
+// PBKDF2-SHA256 (10000 iterations) derived keys, PKCS#7 padded AES256-CTR ciphertext, HMAC-SHA256 of the ciphertext
+const (
+	// "letmein" encrypted with the "secret" password
+	vaultLetmein = `$ANSIBLE_VAULT;1.1;AES256
+30303031303230333034303530363037303830393061306230633064306530663130313131323133
+3134313531363137313831393161316231633164316531660a336565633366323934613133633934
+30666333386161633236363733633565646661616335326262363338323538316461643761313431
+3931376530326139380a613464376434646237396664396161613761333064303163333035633235
+3433`
+	// "deploy@example.com: P@ss w0rd!" encrypted with the "secret" password
+	vaultLong = `$ANSIBLE_VAULT;1.1;AES256
+36343635363636373638363936613662366336643665366637303731373237333734373537363737
+3738373937613762376337643765376638303831383238330a346564303962303263626631376663
+34343538316234656232373661326661333461323034626232336166643231346138363236333566
+3239613037313337320a373431353330383737613533393236383031343335393231303735333331
+31616337623439373333363830616631306130356438373765333835653838313261`
+	// empty string encrypted with the "secret" password
+	vaultEmpty = `$ANSIBLE_VAULT;1.1;AES256
+30303031303230333034303530363037303830393061306230633064306530663130313131323133
+3134313531363137313831393161316231633164316531660a316531343237663231343033623035
+34613665383037353336663830313135643031373837333564316566613661303461616638353264
+6435656636303864330a643861326230613630633834653462333633323963393035323934353363
+3561`
+	// "prod password" encrypted with the "prodpass" password and the "prod" vault id
+	vaultLabeled = `$ANSIBLE_VAULT;1.2;AES256;prod
+30303031303230333034303530363037303830393061306230633064306530663130313131323133
+3134313531363137313831393161316231633164316531660a643366633461363236383962653763
+61316664343336383532303561356632666532653065306239316537383635396639383262613166
+3337643638653862350a646232353036393034333136316466336430643638363164646366363433
+6364`
+	// 16 bytes encrypted with the "secret" password without the PKCS#7 padding
+	vaultBadPadding = `$ANSIBLE_VAULT;1.1;AES256
+30303031303230333034303530363037303830393061306230633064306530663130313131323133
+3134313531363137313831393161316231633164316531660a383734316337356462663863393439
+63336635376631656635343263303230366265656339303634363661623533663834326161383430
+3235666238656466330a663838333932383532386131633239343462303062383737356133313439
+3263`
+)
+
+func TestVaultDecrypt(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		secrets  []vaultSecret
+		expected string
+		err      error
+	}{
+		{"1.1", vaultLetmein, []vaultSecret{{password: []byte("secret")}}, "letmein", nil},
+		{"1.1 longer value", vaultLong, []vaultSecret{{password: []byte("secret")}}, "deploy@example.com: P@ss w0rd!", nil},
+		{"1.1 empty value", vaultEmpty, []vaultSecret{{password: []byte("secret")}}, "", nil},
+		{"indented", "  " + vaultLetmein + "\n", []vaultSecret{{password: []byte("secret")}}, "letmein", nil},
+		{"several passwords", vaultLetmein, []vaultSecret{{password: []byte("wrong")}, {password: []byte("secret")}}, "letmein", nil},
+		{"1.2 with vault id", vaultLabeled, []vaultSecret{{label: "dev", password: []byte("secret")}, {label: "prod", password: []byte("prodpass")}}, "prod password", nil},
+		{"1.2 without matching vault id", vaultLabeled, []vaultSecret{{label: "dev", password: []byte("prodpass")}}, "prod password", nil},
+		{"wrong password", vaultLetmein, []vaultSecret{{password: []byte("wrong")}}, "", ErrVaultHMAC},
+		{"wrong vault id password", vaultLabeled, []vaultSecret{{label: "prod", password: []byte("secret")}}, "", ErrVaultHMAC},
+		{"no password", vaultLetmein, nil, "", ErrVaultNoPassword},
+		{"bad padding", vaultBadPadding, []vaultSecret{{password: []byte("secret")}}, "", ErrVaultFormat},
+		{"unsupported cipher", "$ANSIBLE_VAULT;1.1;AES\n3030", []vaultSecret{{password: []byte("secret")}}, "", ErrVaultFormat},
+		{"not hex", "$ANSIBLE_VAULT;1.1;AES256\nnothex", []vaultSecret{{password: []byte("secret")}}, "", ErrVaultFormat},
+		{"truncated body", "$ANSIBLE_VAULT;1.1;AES256\n3030", []vaultSecret{{password: []byte("secret")}}, "", ErrVaultFormat},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			v := &vault{secrets: test.secrets}
+			plaintext, err := v.Decrypt([]byte(test.data))
+			if !errors.Is(err, test.err) {
+				t.Fatalf("expected error %v, got %v", test.err, err)
+			}
+			if string(plaintext) != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, plaintext)
+			}
+		})
+	}
+}
+
+func TestVaultPasswordSources(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"password": "secret\n"})
+	script := filepath.Join(dir, "password.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\necho prodpass\n"), 0o700); err != nil { //nolint:gosec // the script must be executable
+		t.Fatal(err)
+	}
+	t.Setenv("ANSIBLE_VAULT_PASSWORD_FILE", "")
+	acfg := &ansible.AnsibleCfg{Config: map[string]map[string]string{"defaults": {"vault_identity_list": "prod@" + script}}}
+	v := newVault(&config.Config{VaultPassFile: filepath.Join(dir, "password")}, acfg)
+
+	for _, data := range []string{vaultLetmein, vaultLabeled} {
+		if _, err := v.Decrypt([]byte(data)); err != nil {
+			t.Errorf("cannot decrypt: %v", err)
+		}
+	}
+}
+
+func TestParseInventoryVaultValues(t *testing.T) {
+	t.Setenv("ANSIBLE_VAULT_PASSWORD_FILE", "")
+	t.Setenv("ANSIBLE_INVENTORY", "")
+	indent := func(data string) string {
+		return "    " + strings.ReplaceAll(data, "\n", "\n    ")
+	}
+	tests := []struct {
+		name     string
+		password string
+		vars     string
+		found    bool
+		sshPass  string
+	}{
+		{"decrypted", "secret", "ansible_password: !vault |\n" + indent(vaultLetmein), true, "letmein"},
+		{"wrong password", "wrong", "ansible_password: !vault |\n" + indent(vaultLetmein), false, ""},
+		{"templated user", "wrong", "admin_user: !vault |\n" + indent(vaultLetmein) + "\nansible_user: \"{{ admin_user }}\"", false, ""},
+		{"unrelated var", "wrong", "db_password: !vault |\n" + indent(vaultLetmein), true, ""},
+		{"become password", "wrong", "ansible_become_password: !vault |\n" + indent(vaultLetmein), true, ""},
+		{"ssh options", "wrong", "ansible_ssh_common_args: !vault |\n" + indent(vaultLetmein), false, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeFiles(t, root, map[string]string{
+				"password":                      test.password,
+				"inventory/hosts":               "web01 ansible_host=10.0.0.1\n",
+				"inventory/host_vars/web01.yml": test.vars + "\n",
+			})
+			inv := ParseInventory(&config.Config{Path: "inventory/hosts", VaultPassFile: filepath.Join(root, "password")}, root, "", "")
+			host := inv.Hosts["web01"]
+			if found := host != nil; found != test.found {
+				t.Fatalf("expected host found: %t, got: %t", test.found, found)
+			}
+			if host != nil && host.SSHPass != test.sshPass {
+				t.Errorf("expected ssh password %q, got %q", test.sshPass, host.SSHPass)
+			}
+			if len(inv.Groups[allGroup]) != len(inv.Hosts) {
+				t.Errorf("the skipped host is still within the groups")
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package ansible
+
+import (
+	"os"
+
+	"github.com/etkecc/ansible-ssh/internal/logger"
+	"github.com/etkecc/go-ansible"
+	"gopkg.in/yaml.v3"
+)
+
+// yamlGroup is a group within the YAML inventory
+type yamlGroup struct {
+	Hosts    yamlMap[map[string]any] `yaml:"hosts"`
+	Vars     map[string]any          `yaml:"vars"`
+	Children yamlMap[*yamlGroup]     `yaml:"children"`
+}
+
+// yamlMap is a YAML mapping that keeps the order of keys (hosts order matters for the host patterns, e.g. web[0])
+type yamlMap[T any] struct {
+	keys   []string
+	values map[string]T
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler
+func (m *yamlMap[T]) UnmarshalYAML(node *yaml.Node) error {
+	if err := node.Decode(&m.values); err != nil {
+		return err
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		m.keys = append(m.keys, node.Content[i].Value)
+	}
+	return nil
+}
+
+// newYAMLParser returns YAML inventory (all: children: hosts: vars:) parser,
+// that produces the same structure as INI hosts file
+func newYAMLParser(v *vault) inventoryParser {
+	return func(f string, defaults *ansible.Host) (*ansible.Inventory, error) {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+
+		var root yamlMap[*yamlGroup]
+		if err := decodeYAML(data, &root, v); err != nil {
+			return nil, err
+		}
+
+		tree := newInventoryTree()
+		for _, name := range root.keys {
+			walkYAMLGroup(tree, name, root.values[name], topParent(name))
+		}
+
+		return tree.inventory(defaults), nil
+	}
+}
+
+func walkYAMLGroup(tree *inventoryTree, name string, group *yamlGroup, parent string) {
+	tree.addGroup(name, parent)
+	if group == nil {
+		return
+	}
+
+	tree.addGroupVars(name, group.Vars)
+	for _, host := range group.Hosts.keys {
+		tree.addHost(host, name, group.Hosts.values[host])
+	}
+	for _, child := range group.Children.keys {
+		walkYAMLGroup(tree, child, group.Children.values[child], name)
+	}
+}
+
+// readYAMLVarsFile reads YAML vars file (e.g. host_vars/NAME/vars.yml)
+func readYAMLVarsFile(f string, v *vault) (ansible.HostVars, error) {
+	data, err := os.ReadFile(f)
+	if err != nil {
+		return nil, err
+	}
+
+	vars := ansible.HostVars{}
+	if err := decodeYAML(data, &vars, v); err != nil {
+		return nil, err
+	}
+	return vars, nil
+}
+
+// decodeYAML decodes YAML data, decrypting vault-encrypted data and inline !vault values
+func decodeYAML(data []byte, out any, v *vault) error {
+	if isVaultEncrypted(data) {
+		decrypted, err := v.Decrypt(data)
+		if err != nil {
+			return err
+		}
+		data = decrypted
+	}
+
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return err
+	}
+	if node.Kind == 0 { // empty document
+		return nil
+	}
+	decryptYAMLNode(&node, v)
+
+	return node.Decode(out)
+}
+
+// decryptYAMLNode replaces !vault values with decrypted strings, values that cannot be decrypted are replaced with the undecryptable placeholder.
+// Without the vault (nil), the values are replaced with the placeholder silently
+func decryptYAMLNode(node *yaml.Node, v *vault) {
+	if node.Tag == vaultTag {
+		plaintext, err := v.Decrypt([]byte(node.Value))
+		if err != nil {
+			if v != nil {
+				logger.Warn("cannot decrypt vault value at line", node.Line, "error:", err)
+			}
+			plaintext = []byte(undecryptable)
+		}
+		node.Tag = "!!str"
+		node.Value = string(plaintext)
+	}
+	for _, child := range node.Content {
+		decryptYAMLNode(child, v)
+	}
+}
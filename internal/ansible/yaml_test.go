@@ -0,0 +1,179 @@
+package ansible
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/etkecc/go-ansible"
+)
+
+func TestYAMLParser(t *testing.T) {
+	type expectedHost struct {
+		user   string
+		port   int
+		group  string
+		groups []string
+	}
+	tests := []struct {
+		name   string
+		file   string
+		data   string
+		hosts  map[string]expectedHost
+		groups map[string][]string // group -> host names, in order
+	}{
+		{
+			name: "nested children",
+			file: "hosts.yml",
+			data: `all:
+  children:
+    matrix:
+      children:
+        web:
+          hosts:
+            web02:
+            web01:
+        db:
+          hosts:
+            db01:
+`,
+			hosts: map[string]expectedHost{
+				"web01": {group: "web", groups: []string{"web", "all", "matrix"}},
+				"web02": {group: "web", groups: []string{"web", "all", "matrix"}},
+				"db01":  {group: "db", groups: []string{"db", "all", "matrix"}},
+			},
+			groups: map[string][]string{"matrix": {"web02", "web01", "db01"}, "web": {"web02", "web01"}, allGroup: {"web02", "web01", "db01"}},
+		},
+		{
+			name: "var precedence",
+			file: "hosts.yaml",
+			data: `all:
+  vars:
+    ansible_user: all
+    ansible_port: 2200
+  children:
+    web:
+      vars:
+        ansible_user: web
+      children:
+        prod:
+          vars:
+            ansible_user: prod
+          hosts:
+            web01:
+            web02:
+              ansible_user: own
+`,
+			hosts: map[string]expectedHost{
+				"web01": {user: "prod", port: 2200, group: "prod", groups: []string{"prod", "all", "web"}},
+				"web02": {user: "own", port: 2200, group: "prod", groups: []string{"prod", "all", "web"}},
+			},
+			groups: map[string][]string{"web": {"web01", "web02"}},
+		},
+		{
+			name: "several direct groups",
+			file: "hosts.yml",
+			data: `zeta:
+  hosts:
+    web01:
+alpha:
+  hosts:
+    web01:
+`,
+			hosts: map[string]expectedHost{
+				"web01": {group: "alpha", groups: []string{"zeta", "alpha", "all"}},
+			},
+		},
+		{
+			name: "json",
+			file: "hosts.json",
+			data: `{"all": {"children": {"web": {"hosts": {"web01": {"ansible_user": "admin", "ansible_port": 2222}}}}}}`,
+			hosts: map[string]expectedHost{
+				"web01": {user: "admin", port: 2222, group: "web", groups: []string{"web", "all"}},
+			},
+		},
+		{
+			name: "empty groups",
+			file: "hosts.yml",
+			data: `all:
+  hosts:
+    web01:
+  children:
+    empty:
+    nohosts:
+      hosts:
+`,
+			hosts: map[string]expectedHost{
+				"web01": {group: ungroupedGroup, groups: []string{ungroupedGroup, "all"}},
+			},
+			groups: map[string][]string{"empty": nil, "nohosts": nil, ungroupedGroup: {"web01"}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, map[string]string{test.file: test.data})
+
+			inv, err := newYAMLParser(nil)(filepath.Join(dir, test.file), &ansible.Host{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(inv.Hosts) != len(test.hosts) {
+				t.Errorf("expected %d hosts, got %d", len(test.hosts), len(inv.Hosts))
+			}
+			for name, expected := range test.hosts {
+				host := inv.Hosts[name]
+				if host == nil {
+					t.Fatalf("host %s is not found", name)
+				}
+				if host.User != expected.user || host.Port != expected.port || host.Group != expected.group {
+					t.Errorf("expected %s user %q, port %d, group %q, got %q, %d, %q", name, expected.user, expected.port, expected.group, host.User, host.Port, host.Group)
+				}
+				if !slices.Equal(host.Groups, expected.groups) {
+					t.Errorf("expected %s groups %v, got %v", name, expected.groups, host.Groups)
+				}
+			}
+			for group, expected := range test.groups {
+				hosts, ok := inv.Groups[group]
+				if !ok {
+					t.Errorf("group %s is not found", group)
+				}
+				names := []string{}
+				for _, host := range hosts {
+					names = append(names, host.Name)
+				}
+				if !slices.Equal(names, expected) && (len(names) != 0 || len(expected) != 0) {
+					t.Errorf("expected group %s hosts %v, got %v", group, expected, names)
+				}
+			}
+		})
+	}
+}
+
+func TestDetectParser(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		user string
+	}{
+		{"ini", "[web]\nweb01 ansible_user=ini\n", "ini"},
+		{"yaml", "# comment\nall:\n  hosts:\n    web01:\n      ansible_user: yaml\n", "yaml"},
+		{"yaml document", "---\nweb:\n  hosts:\n    web01:\n      ansible_user: document\n", "document"},
+		{"json", `{"web": {"hosts": {"web01": {"ansible_user": "json"}}}}`, "json"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, map[string]string{"hosts": test.data})
+			invPath := filepath.Join(dir, "hosts")
+
+			inv, err := detectParser(nil, nil, invPath)(invPath, &ansible.Host{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if host := inv.Hosts["web01"]; host == nil || host.User != test.user {
+				t.Errorf("expected web01 with user %q, got %+v", test.user, host)
+			}
+		})
+	}
+}
@@ -0,0 +1,403 @@
+// Package broadcast provides the cluster-ssh-like terminal: every host runs under its own pseudo-terminal,
+// one host is shown at a time (tabs), and the keystrokes are sent to all hosts of the broadcast set
+package broadcast
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/etkecc/ansible-ssh/internal/term"
+)
+
+const (
+	// prefixKey is the command key (Ctrl+]), followed by the command
+	prefixKey = 0x1d
+	// escapeKey starts Alt+key sequences
+	escapeKey = 0x1b
+	// escapeDelay is the time to wait for the digit after ESC that came as the last byte of the input,
+	// after that it is sent as is (e.g. the ESC key pressed within vim)
+	escapeDelay = 50 * time.Millisecond
+	// scrollback is the size of the recent output kept per host, replayed when the host is shown
+	scrollback = 64 * 1024
+	// help is the list of the commands shown within the status line
+	help = "^]: 1-9 show, n/p next/prev, t toggle, a all, q quit; Alt+1-9 toggle | "
+)
+
+// Target is the host to run the command (e.g. ssh) for
+type Target struct {
+	Name string
+	Argv []string
+	Env  []string // env vars of the target command, in addition to the common ones
+}
+
+// session is the running command of the target
+type session struct {
+	name    string
+	cmd     *exec.Cmd
+	pty     *os.File
+	enabled bool   // within the broadcast set
+	done    bool   // the command has exited
+	output  []byte // recent output
+}
+
+// terminal is the broadcast terminal state, guarded by the mutex
+type terminal struct {
+	mu       sync.Mutex
+	sessions []*session
+	active   int // index of the shown session
+	width    int
+	height   int
+	out      io.Writer
+	prefix   bool // the prefix key has been pressed
+	escape   int  // id of the pending ESC that came as the last byte of the input, 0 if there is none
+	escapes  int  // the last pending ESC id
+	finished chan struct{}
+}
+
+// Run starts the targets under their own pseudo-terminals and attaches the current terminal to them until all of them exit or the user quits
+func Run(targets []Target, environ []string) error {
+	in, out := os.Stdin, os.Stdout
+	if !term.IsTerminal(in.Fd()) || !term.IsTerminal(out.Fd()) {
+		return errors.New("stdin and stdout must be a terminal")
+	}
+	t := &terminal{out: out, finished: make(chan struct{})}
+	t.width, t.height = termSize(out)
+	for _, target := range targets {
+		s, err := t.start(target, environ)
+		if err != nil {
+			t.stop()
+			return fmt.Errorf("cannot start %s: %w", target.Name, err)
+		}
+		t.sessions = append(t.sessions, s)
+	}
+
+	state, err := term.MakeRaw(in.Fd())
+	if err != nil {
+		t.stop()
+		return err
+	}
+	defer term.Restore(in.Fd(), state) //nolint:errcheck // nothing to do with the error here
+	defer func() {
+		// reset the scroll region and clear the status line
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		io.WriteString(out, "\x1b[r\x1b["+strconv.Itoa(t.height)+";1H\x1b[2K") //nolint:errcheck // nothing to do with the error here
+	}()
+
+	t.mu.Lock()
+	t.redraw()
+	t.mu.Unlock()
+	for _, s := range t.sessions {
+		readDone := make(chan struct{})
+		go t.read(s, readDone)
+		go t.wait(s, readDone)
+	}
+	go t.input(in)
+	stopResize := notifyResize(func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		t.width, t.height = termSize(out)
+		for _, s := range t.sessions {
+			term.SetSize(s.pty.Fd(), t.width, t.height-1) //nolint:errcheck // nothing to do with the error here
+		}
+		t.redraw()
+	})
+	defer stopResize()
+
+	<-t.finished
+	t.stop()
+	return nil
+}
+
+// start starts the target command under the new pseudo-terminal
+func (t *terminal) start(target Target, environ []string) (*session, error) {
+	master, slave, err := term.OpenPTY()
+	if err != nil {
+		return nil, err
+	}
+	defer slave.Close()
+	term.SetSize(master.Fd(), t.width, t.height-1) //nolint:errcheck // the default size is used on error
+
+	cmd := exec.Command(target.Argv[0], target.Argv[1:]...) //nolint:gosec // that's intended
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = slave, slave, slave
+	cmd.Env = slices.Concat(os.Environ(), environ, target.Env)
+	withControllingTerminal(cmd)
+	if err := cmd.Start(); err != nil {
+		master.Close()
+		return nil, err
+	}
+	return &session{name: target.Name, cmd: cmd, pty: master, enabled: true}, nil
+}
+
+// stop kills the commands that are still running
+func (t *terminal) stop() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, s := range t.sessions {
+		if !s.done && s.cmd.Process != nil {
+			s.cmd.Process.Kill() //nolint:errcheck // nothing to do with the error here
+		}
+	}
+}
+
+// read copies the session output into the scrollback, and to the terminal if the session is shown
+func (t *terminal) read(s *session, readDone chan struct{}) {
+	defer close(readDone)
+	buf := make([]byte, 4096)
+	for {
+		n, err := s.pty.Read(buf)
+		if n > 0 {
+			t.mu.Lock()
+			s.output = appendScrollback(s.output, buf[:n])
+			if t.sessions[t.active] == s {
+				t.out.Write(buf[:n]) //nolint:errcheck // nothing to do with the error here
+				if clearsStatus(buf[:n]) {
+					t.status()
+				}
+			}
+			t.mu.Unlock()
+		}
+		if err != nil { // EIO once the command exits, or the pty is closed
+			return
+		}
+	}
+}
+
+// wait waits for the session command to exit, and finishes the terminal once all commands exit.
+// The pty is closed after the output has been read, or after a second if the command left a background process holding the pty
+func (t *terminal) wait(s *session, readDone chan struct{}) {
+	s.cmd.Wait() //nolint:errcheck // the exit code is not relevant
+	select {
+	case <-readDone:
+	case <-time.After(time.Second):
+	}
+	s.pty.Close()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s.done = true
+	s.output = appendScrollback(s.output, []byte("\r\n["+s.name+" exited]\r\n"))
+	for _, other := range t.sessions {
+		if !other.done {
+			t.status()
+			return
+		}
+	}
+	close(t.finished)
+}
+
+// input handles the keystrokes: the commands after the prefix key, Alt+digit toggles, everything else goes to the broadcast set
+func (t *terminal) input(in io.Reader) {
+	buf := make([]byte, 1024)
+	for {
+		n, err := in.Read(buf)
+		if err != nil {
+			return
+		}
+		t.mu.Lock()
+		quit := t.handle(buf[:n])
+		t.mu.Unlock()
+		if quit {
+			t.stop()
+			return
+		}
+	}
+}
+
+// handle processes the input, returns true if the user quits
+func (t *terminal) handle(input []byte) bool {
+	var pending []byte
+	if t.escape != 0 { // ESC came as the last byte of the previous input
+		t.escape = 0
+		if len(input) > 0 && input[0] >= '1' && input[0] <= '9' {
+			t.toggle(int(input[0] - '1'))
+			input = input[1:]
+		} else {
+			pending = append(pending, escapeKey)
+		}
+	}
+	flush := func() {
+		t.send(pending)
+		pending = nil
+	}
+	for i := 0; i < len(input); i++ {
+		b := input[i]
+		switch {
+		case t.prefix:
+			t.prefix = false
+			if b == prefixKey {
+				pending = append(pending, b)
+				continue
+			}
+			flush()
+			if t.command(b) {
+				return true
+			}
+		case b == prefixKey:
+			t.prefix = true
+		case b == escapeKey && i+1 == len(input): // the digit may come within the next input
+			flush()
+			t.escapes++
+			escape := t.escapes
+			t.escape = escape
+			time.AfterFunc(escapeDelay, func() { t.flushEscape(escape) })
+		case b == escapeKey && input[i+1] >= '1' && input[i+1] <= '9': // Alt+digit
+			flush()
+			t.toggle(int(input[i+1] - '1'))
+			i++
+		default:
+			pending = append(pending, b)
+		}
+	}
+	flush()
+	return false
+}
+
+// flushEscape sends the pending ESC, if it is still pending
+func (t *terminal) flushEscape(escape int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.escape == escape {
+		t.escape = 0
+		t.send([]byte{escapeKey})
+	}
+}
+
+// command runs the command key pressed after the prefix key, returns true if the user quits
+func (t *terminal) command(key byte) bool {
+	switch {
+	case key >= '1' && key <= '9':
+		if idx := int(key - '1'); idx < len(t.sessions) {
+			t.show(idx)
+		}
+	case key == 'n':
+		t.show((t.active + 1) % len(t.sessions))
+	case key == 'p':
+		t.show((t.active - 1 + len(t.sessions)) % len(t.sessions))
+	case key == 't' || key == ' ':
+		t.toggle(t.active)
+	case key == 'a':
+		all := true
+		for _, s := range t.sessions {
+			all = all && s.enabled
+		}
+		for i, s := range t.sessions {
+			s.enabled = !all || i == t.active
+		}
+		t.status()
+	case key == 'q':
+		return true
+	}
+	return false
+}
+
+// send writes the input to every running session of the broadcast set
+func (t *terminal) send(data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	for _, s := range t.sessions {
+		if s.enabled && !s.done {
+			s.pty.Write(data) //nolint:errcheck // the session may exit at any time
+		}
+	}
+}
+
+// toggle adds the session into the broadcast set or removes it from there
+func (t *terminal) toggle(idx int) {
+	if idx >= len(t.sessions) {
+		return
+	}
+	t.sessions[idx].enabled = !t.sessions[idx].enabled
+	t.status()
+}
+
+// show switches the terminal to the session: the scrollback is replayed,
+// and the pty size is nudged, so full-screen programs redraw themselves
+func (t *terminal) show(idx int) {
+	t.active = idx
+	t.redraw()
+	s := t.sessions[idx]
+	if !s.done {
+		term.SetSize(s.pty.Fd(), t.width, t.height-2) //nolint:errcheck // nothing to do with the error here
+		term.SetSize(s.pty.Fd(), t.width, t.height-1) //nolint:errcheck // nothing to do with the error here
+	}
+}
+
+// redraw clears the screen, replays the scrollback of the shown session and draws the status line
+func (t *terminal) redraw() {
+	var b bytes.Buffer
+	b.WriteString("\x1b[r\x1b[H\x1b[2J")
+	b.WriteString("\x1b[1;" + strconv.Itoa(t.height-1) + "r\x1b[H") // the last row is reserved for the status line
+	b.Write(t.sessions[t.active].output)
+	t.out.Write(b.Bytes()) //nolint:errcheck // nothing to do with the error here
+	t.status()
+}
+
+// status draws the status line with the hosts: the shown one is bracketed, the ones within the broadcast set are marked with *
+func (t *terminal) status() {
+	tabs := make([]string, 0, len(t.sessions))
+	for i, s := range t.sessions {
+		tab := strconv.Itoa(i+1) + ":" + s.name
+		if s.enabled {
+			tab += "*"
+		}
+		if s.done {
+			tab += "(exited)"
+		}
+		if i == t.active {
+			tab = "[" + tab + "]"
+		}
+		tabs = append(tabs, tab)
+	}
+	line := help + strings.Join(tabs, " ")
+	if len(line) > t.width {
+		line = line[len(help):]
+	}
+	if len(line) > t.width {
+		line = line[:t.width]
+	}
+	// save the cursor, draw the status line on the last row in reverse video, restore the cursor
+	io.WriteString(t.out, "\x1b7\x1b["+strconv.Itoa(t.height)+";1H\x1b[7m"+line+"\x1b[K\x1b[0m\x1b8") //nolint:errcheck // nothing to do with the error here
+}
+
+// clearsStatus returns true if the output may have erased the status line (clear screen, reset, scroll region reset)
+func clearsStatus(output []byte) bool {
+	for _, seq := range []string{"\x1b[2J", "\x1b[J", "\x1b[0J", "\x1bc", "\x1b[r", "\x1b[?1049"} {
+		if bytes.Contains(output, []byte(seq)) {
+			return true
+		}
+	}
+	return false
+}
+
+// appendScrollback appends the data to the scrollback, keeping the recent output only (starting with a complete line)
+func appendScrollback(output, data []byte) []byte {
+	output = append(output, data...)
+	if len(output) <= scrollback {
+		return output
+	}
+	output = output[len(output)-scrollback:]
+	if idx := bytes.IndexByte(output, '\n'); idx != -1 {
+		output = output[idx+1:]
+	}
+	return append([]byte{}, output...)
+}
+
+// termSize returns the terminal size, 80x24 if it cannot be detected
+func termSize(out *os.File) (width, height int) {
+	width, height, err := term.Size(out.Fd())
+	if err != nil || width <= 0 || height <= 1 {
+		return 80, 24
+	}
+	return width, height
+}
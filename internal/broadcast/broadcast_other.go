@@ -0,0 +1,13 @@
+//go:build !linux && !darwin
+
+package broadcast
+
+import "os/exec"
+
+// withControllingTerminal is a no-op on this platform, the pseudo-terminals are not supported here
+func withControllingTerminal(_ *exec.Cmd) {}
+
+// notifyResize is a no-op on this platform
+func notifyResize(_ func()) func() {
+	return func() {}
+}
@@ -0,0 +1,230 @@
+package broadcast
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/etkecc/ansible-ssh/internal/term"
+)
+
+// newTestTerminal returns the terminal with the sessions writing their input into the pipes, and the read ends of the pipes
+func newTestTerminal(t *testing.T, names ...string) (*terminal, []*os.File) {
+	t.Helper()
+	term := &terminal{out: &bytes.Buffer{}, width: 80, height: 24, finished: make(chan struct{})}
+	inputs := make([]*os.File, 0, len(names))
+	for _, name := range names {
+		r, w, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() {
+			r.Close()
+			w.Close()
+		})
+		term.sessions = append(term.sessions, &session{name: name, pty: w, enabled: true})
+		inputs = append(inputs, r)
+	}
+	return term, inputs
+}
+
+// received returns the input that has been sent to the session so far
+func received(t *testing.T, input *os.File) string {
+	t.Helper()
+	var b bytes.Buffer
+	buf := make([]byte, 1024)
+	for {
+		input.SetReadDeadline(time.Now().Add(20 * time.Millisecond)) //nolint:errcheck // nothing to do with the error here
+		n, err := input.Read(buf)
+		b.Write(buf[:n])
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return b.String()
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestHandle(t *testing.T) {
+	tests := []struct {
+		name     string
+		inputs   []string // separate reads
+		expected []string // input received by every session
+		enabled  []bool   // broadcast set after the input
+		active   int
+		quit     bool
+	}{
+		{"fan-out", []string{"ls -la\r"}, []string{"ls -la\r", "ls -la\r", "ls -la\r"}, []bool{true, true, true}, 0, false},
+		{"show", []string{"\x1d2", "x"}, []string{"x", "x", "x"}, []bool{true, true, true}, 1, false},
+		{"show missing", []string{"\x1d9"}, []string{"", "", ""}, []bool{true, true, true}, 0, false},
+		{"next and previous", []string{"\x1dn\x1dn\x1dn\x1dp"}, []string{"", "", ""}, []bool{true, true, true}, 2, false},
+		{"prefix split across reads", []string{"\x1d", "n"}, []string{"", "", ""}, []bool{true, true, true}, 1, false},
+		{"literal prefix", []string{"a\x1d\x1db"}, []string{"a\x1db", "a\x1db", "a\x1db"}, []bool{true, true, true}, 0, false},
+		{"toggle", []string{"\x1dt", "x"}, []string{"", "x", "x"}, []bool{false, true, true}, 0, false},
+		{"toggle with space", []string{"\x1d ", "\x1d "}, []string{"", "", ""}, []bool{true, true, true}, 0, false},
+		{"only the shown one", []string{"\x1d2\x1da", "x"}, []string{"", "x", ""}, []bool{false, true, false}, 1, false},
+		{"all again", []string{"\x1da\x1da", "x"}, []string{"x", "x", "x"}, []bool{true, true, true}, 0, false},
+		{"alt+digit", []string{"a\x1b2b"}, []string{"ab", "a", "ab"}, []bool{true, false, true}, 0, false},
+		{"alt+digit split across reads", []string{"a\x1b", "2b"}, []string{"ab", "a", "ab"}, []bool{true, false, true}, 0, false},
+		{"escape split across reads", []string{"a\x1b", "[A"}, []string{"a\x1b[A", "a\x1b[A", "a\x1b[A"}, []bool{true, true, true}, 0, false},
+		{"escape sequence", []string{"\x1b[A\x1bOB"}, []string{"\x1b[A\x1bOB", "\x1b[A\x1bOB", "\x1b[A\x1bOB"}, []bool{true, true, true}, 0, false},
+		{"quit", []string{"a\x1dqb"}, []string{"a", "a", "a"}, []bool{true, true, true}, 0, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			term, inputs := newTestTerminal(t, "web01", "web02", "web03")
+			var quit bool
+			term.mu.Lock()
+			for _, input := range test.inputs {
+				if quit = term.handle([]byte(input)); quit {
+					break
+				}
+			}
+			term.mu.Unlock()
+
+			if quit != test.quit || term.active != test.active {
+				t.Errorf("expected quit %t and active %d, got %t and %d", test.quit, test.active, quit, term.active)
+			}
+			for i, s := range term.sessions {
+				if got := received(t, inputs[i]); got != test.expected[i] {
+					t.Errorf("expected %s to receive %q, got %q", s.name, test.expected[i], got)
+				}
+				if s.enabled != test.enabled[i] {
+					t.Errorf("expected %s enabled %t, got %t", s.name, test.enabled[i], s.enabled)
+				}
+			}
+		})
+	}
+}
+
+func TestHandlePendingEscape(t *testing.T) {
+	term, inputs := newTestTerminal(t, "web01", "web02")
+	term.mu.Lock()
+	term.handle([]byte("\x1b"))
+	term.mu.Unlock()
+	if got := received(t, inputs[0]); got != "" {
+		t.Errorf("expected ESC to be pending, got %q", got)
+	}
+
+	time.Sleep(2 * escapeDelay)
+	for i, input := range inputs {
+		if got := received(t, input); got != "\x1b" {
+			t.Errorf("expected ESC to be sent to %s after the delay, got %q", term.sessions[i].name, got)
+		}
+	}
+	term.mu.Lock()
+	term.handle([]byte("2"))
+	term.mu.Unlock()
+	if got := received(t, inputs[1]); got != "2" || !term.sessions[1].enabled {
+		t.Errorf("expected the digit to be sent as is, got %q", got)
+	}
+}
+
+func TestHandleSkipsExitedSessions(t *testing.T) {
+	term, inputs := newTestTerminal(t, "web01", "web02")
+	term.sessions[1].done = true
+	term.mu.Lock()
+	term.handle([]byte("uptime\r"))
+	term.mu.Unlock()
+	if got := received(t, inputs[0]); got != "uptime\r" {
+		t.Errorf("expected web01 to receive the input, got %q", got)
+	}
+	if got := received(t, inputs[1]); got != "" {
+		t.Errorf("expected the exited web02 to receive nothing, got %q", got)
+	}
+}
+
+func TestWait(t *testing.T) {
+	term, _ := newTestTerminal(t, "web01", "web02")
+	for i, s := range term.sessions {
+		s.cmd = exec.Command("sh", "-c", "exit 0")
+		if err := s.cmd.Start(); err != nil {
+			t.Fatal(err)
+		}
+		readDone := make(chan struct{})
+		close(readDone)
+		term.wait(s, readDone)
+
+		if !s.done || !strings.Contains(string(s.output), "["+s.name+" exited]") {
+			t.Errorf("expected %s to be marked as exited, got done %t and output %q", s.name, s.done, s.output)
+		}
+		select {
+		case <-term.finished:
+			if i != len(term.sessions)-1 {
+				t.Error("the terminal finished before all sessions exited")
+			}
+		default:
+			if i == len(term.sessions)-1 {
+				t.Error("the terminal is not finished after all sessions exited")
+			}
+		}
+	}
+}
+
+func TestSession(t *testing.T) {
+	if _, _, err := term.OpenPTY(); errors.Is(err, errors.ErrUnsupported) {
+		t.Skip("pseudo-terminals are not supported")
+	}
+	out := &bytes.Buffer{}
+	tm := &terminal{out: out, width: 80, height: 24, finished: make(chan struct{})}
+	for _, name := range []string{"web01", "web02"} {
+		s, err := tm.start(Target{Name: name, Argv: []string{"sh", "-c", `read line; echo "$HOST got $line"`}, Env: []string{"HOST=" + name}}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tm.sessions = append(tm.sessions, s)
+	}
+	for _, s := range tm.sessions {
+		readDone := make(chan struct{})
+		go tm.read(s, readDone)
+		go tm.wait(s, readDone)
+	}
+
+	tm.mu.Lock()
+	tm.handle([]byte("hello\r"))
+	tm.mu.Unlock()
+	select {
+	case <-tm.finished:
+	case <-time.After(5 * time.Second):
+		tm.stop()
+		t.Fatal("the sessions did not exit")
+	}
+
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	for _, s := range tm.sessions {
+		if expected := s.name + " got hello"; !strings.Contains(string(s.output), expected) {
+			t.Errorf("expected %q within the %s output %q", expected, s.name, s.output)
+		}
+	}
+	if !strings.Contains(out.String(), "web01 got hello") || strings.Contains(out.String(), "web02 got hello") {
+		t.Errorf("expected the output of the shown web01 only, got %q", out.String())
+	}
+}
+
+func TestAppendScrollback(t *testing.T) {
+	tests := []struct {
+		name     string
+		writes   []string
+		expected string
+	}{
+		{"short", []string{"first\n", "second"}, "first\nsecond"},
+		{"trimmed to the complete line", []string{"first\n", strings.Repeat("x", scrollback-10), "\nmiddle\n", "last"}, "middle\nlast"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var output []byte
+			for _, data := range test.writes {
+				output = appendScrollback(output, []byte(data))
+			}
+			if string(output) != test.expected {
+				t.Errorf("expected %q, got %d bytes", test.expected, len(output))
+			}
+		})
+	}
+}
@@ -7,12 +7,20 @@ import (
 )
 
 type Config struct {
-	Path          string            `yaml:"path"`
-	InventoryOnly bool              `yaml:"inventory_only"`
-	SSHCommand    string            `yaml:"ssh_command"`
-	Debug         bool              `yaml:"debug"`
-	Environ       map[string]string `yaml:"environ"`
-	Defaults      Defaults          `yaml:"defaults"`
+	Path           string            `yaml:"path"`
+	InventoryOnly  bool              `yaml:"inventory_only"`
+	SSHCommand     string            `yaml:"ssh_command"`
+	Debug          bool              `yaml:"debug"`
+	PrintPasswords bool              `yaml:"print_passwords"`
+	Environ        map[string]string `yaml:"environ"`
+	Defaults       Defaults          `yaml:"defaults"`
+	Script         Script            `yaml:"inventory_script"`
+	VaultPassFile  string            `yaml:"vault_password_file"`
+	PlaybookDir    string            `yaml:"playbook_dir"`
+	Inventories    []string          `yaml:"inventories"`
+	Suggest        Suggest           `yaml:"suggest"`
+	Exec           Exec              `yaml:"exec"`
+	Tmux           Tmux              `yaml:"tmux"`
 }
 
 type Defaults struct {
@@ -23,6 +31,34 @@ type Defaults struct {
 	PrivateKeys []string `yaml:"private_keys"`
 }
 
+// Script is the dynamic inventory script configuration
+type Script struct {
+	Timeout  int `yaml:"timeout"`   // script execution timeout in seconds, default 30
+	CacheTTL int `yaml:"cache_ttl"` // script output cache TTL in seconds, 0 disables the cache
+}
+
+// Suggest is the "did you mean" suggestions configuration, used when the host is not found
+type Suggest struct {
+	Limit       int     `yaml:"limit"`        // max number of suggestions, default 5
+	AutoConnect float64 `yaml:"auto_connect"` // connect to the only suggestion with the similarity score (0..1) not lower than that, 0 disables it
+	Prompt      bool    `yaml:"prompt"`       // ask which suggestion to use, enabled by inventory_only as well
+}
+
+// Exec is the `ansible-ssh exec` subcommand configuration
+type Exec struct {
+	Forks   int  `yaml:"forks"`   // max number of hosts to run the command on in parallel, default 5
+	Timeout int  `yaml:"timeout"` // per-host timeout in seconds, 0 disables it
+	Collect bool `yaml:"collect"` // print the output per host when the command finishes, instead of the lines prefixed with the host names
+}
+
+// Tmux is the `ansible-ssh tmux` subcommand configuration
+type Tmux struct {
+	Session string `yaml:"session"` // session name, default: ansible-ssh-PATTERN
+	Layout  string `yaml:"layout"`  // layout of the panes, default: tiled
+	Windows bool   `yaml:"windows"` // one window per host instead of one pane per host
+	Sync    bool   `yaml:"sync"`    // enable synchronize-panes
+}
+
 // Read config from file system
 func Read(configPath string) (*Config, error) {
 	configb, err := os.ReadFile(configPath)
@@ -0,0 +1,109 @@
+package history
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/adrg/xdg"
+
+	"github.com/etkecc/ansible-ssh/internal/logger"
+)
+
+// maxAge is the age of the history entries to be forgotten
+const maxAge = 180 * 24 * time.Hour
+
+// Entry is the connections history of a single host
+type Entry struct {
+	Count int       `json:"count"`
+	Last  time.Time `json:"last"`
+}
+
+// History is the connections history, used to rank hosts by frecency (frequency + recency)
+type History struct {
+	path    string
+	Entries map[string]*Entry `json:"entries"`
+}
+
+// Load reads the connections history from the XDG state dir, missing or broken history is treated as empty
+func Load() *History {
+	h := &History{
+		path:    filepath.Join(xdg.StateHome, "ansible-ssh", "history.json"),
+		Entries: map[string]*Entry{},
+	}
+	data, err := os.ReadFile(h.path)
+	if err != nil {
+		return h
+	}
+	if err := json.Unmarshal(data, h); err != nil {
+		logger.Debug("cannot parse", h.path, "error:", err)
+	}
+	if h.Entries == nil {
+		h.Entries = map[string]*Entry{}
+	}
+	return h
+}
+
+// Record adds the connection to the host and saves the history
+func (h *History) Record(key string) {
+	entry := h.Entries[key]
+	if entry == nil {
+		entry = &Entry{}
+		h.Entries[key] = entry
+	}
+	entry.Count++
+	entry.Last = time.Now()
+	h.save()
+}
+
+// Score returns the frecency score of the host: the number of connections weighted by the last connection age
+func (h *History) Score(key string) float64 {
+	entry := h.Entries[key]
+	if entry == nil {
+		return 0
+	}
+	age := time.Since(entry.Last)
+	var weight float64
+	switch {
+	case age < 4*time.Hour:
+		weight = 100
+	case age < 24*time.Hour:
+		weight = 80
+	case age < 7*24*time.Hour:
+		weight = 60
+	case age < 30*24*time.Hour:
+		weight = 40
+	case age < 90*24*time.Hour:
+		weight = 20
+	default:
+		weight = 10
+	}
+	return float64(entry.Count) * weight
+}
+
+// save writes the history, old entries are removed
+func (h *History) save() {
+	for key, entry := range h.Entries {
+		if time.Since(entry.Last) > maxAge {
+			delete(h.Entries, key)
+		}
+	}
+	data, err := json.Marshal(h)
+	if err != nil {
+		logger.Debug("cannot marshal history:", err)
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(h.path), 0o700); err != nil {
+		logger.Debug("cannot create history dir:", err)
+		return
+	}
+	tmp := h.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		logger.Debug("cannot write history:", err)
+		return
+	}
+	if err := os.Rename(tmp, h.path); err != nil {
+		logger.Debug("cannot write history:", err)
+	}
+}
@@ -1,6 +1,7 @@
 package logger
 
 import (
+	"io"
 	"log"
 	"os"
 )
@@ -8,6 +9,7 @@ import (
 var (
 	withDebug bool
 	logger    = log.New(os.Stdout, "[ansible-ssh] ", 0)
+	warnings  = log.New(os.Stderr, "[ansible-ssh] ", 0)
 )
 
 // Configure the logger package
@@ -15,11 +17,22 @@ func Configure(debug bool) {
 	withDebug = debug
 }
 
+// SetOutput sets the output destination of the logger,
+// e.g. os.Stderr when stdout is used as a data channel
+func SetOutput(w io.Writer) {
+	logger.SetOutput(w)
+}
+
 // Println logs the arguments to the standard logger.
 func Println(args ...any) {
 	logger.Println(args...)
 }
 
+// Warn logs the arguments to stderr, so the diagnostics do not mix with the command output (e.g. `ansible-ssh host cmd > file`)
+func Warn(args ...any) {
+	warnings.Println(args...)
+}
+
 // Debug logs the arguments to the standard logger if the debug flag is set.
 func Debug(args ...any) {
 	if !withDebug {
@@ -0,0 +1,231 @@
+package picker
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/etkecc/ansible-ssh/internal/term"
+)
+
+// ErrCanceled is returned when the user quits the picker without picking anything
+var ErrCanceled = errors.New("canceled")
+
+// Item is a host within the picker
+type Item struct {
+	Name    string
+	Group   string
+	Address string
+	User    string
+	Port    int
+	Score   float64 // frecency score, items with higher score go first
+}
+
+// picker is the full-screen host picker state
+type picker struct {
+	items    []Item
+	query    []rune
+	visible  []int // indexes of the items that match the query, in the display order
+	selected int   // index within visible
+	offset   int   // first displayed row
+	out      io.Writer
+}
+
+// Pick shows the full-screen picker with incremental fuzzy filtering over the items (grouped by the item group),
+// and returns the index of the picked item
+func Pick(items []Item) (int, error) {
+	in, out := os.Stdin, os.Stdout
+	if !term.IsTerminal(in.Fd()) || !term.IsTerminal(out.Fd()) {
+		return -1, errors.ErrUnsupported
+	}
+	state, err := term.MakeRaw(in.Fd())
+	if err != nil {
+		return -1, err
+	}
+	defer term.Restore(in.Fd(), state) //nolint:errcheck // nothing to do with the error here
+
+	io.WriteString(out, "\x1b[?1049h")       //nolint:errcheck // alternate screen
+	defer io.WriteString(out, "\x1b[?1049l") //nolint:errcheck // back to the main screen
+
+	p := &picker{items: items, out: out}
+	p.filter()
+	buf := make([]byte, 256)
+	for {
+		width, height, err := term.Size(out.Fd())
+		if err != nil || width <= 0 || height <= 0 {
+			width, height = 80, 24
+		}
+		p.render(width, height)
+
+		n, err := in.Read(buf)
+		if err != nil {
+			return -1, err
+		}
+		if idx, done, err := p.handle(buf[:n]); done {
+			return idx, err
+		}
+	}
+}
+
+// handle processes the input, returns true if the picker is done
+func (p *picker) handle(input []byte) (idx int, done bool, err error) {
+	for len(input) > 0 {
+		switch {
+		case input[0] == 0x1b && len(input) == 1: // Esc
+			return -1, true, ErrCanceled
+		case input[0] == 0x1b:
+			input = p.handleEscape(input)
+			continue
+		case input[0] == 3 || (input[0] == 4 && len(p.query) == 0): // Ctrl+C, Ctrl+D
+			return -1, true, ErrCanceled
+		case input[0] == '\r' || input[0] == '\n':
+			if len(p.visible) == 0 {
+				break
+			}
+			return p.visible[p.selected], true, nil
+		case input[0] == 127 || input[0] == 8: // Backspace
+			if len(p.query) > 0 {
+				p.query = p.query[:len(p.query)-1]
+				p.filter()
+			}
+		case input[0] == 21: // Ctrl+U
+			p.query = nil
+			p.filter()
+		case input[0] == 23: // Ctrl+W
+			trimmed := strings.TrimRightFunc(string(p.query), unicode.IsSpace)
+			if idx := strings.LastIndexFunc(trimmed, unicode.IsSpace); idx >= 0 {
+				p.query = []rune(trimmed[:idx+1])
+			} else {
+				p.query = nil
+			}
+			p.filter()
+		case input[0] == 14 || input[0] == '\t': // Ctrl+N, Tab
+			p.move(1)
+		case input[0] == 16: // Ctrl+P
+			p.move(-1)
+		case input[0] >= 0x20:
+			r, size := utf8.DecodeRune(input)
+			if r != utf8.RuneError {
+				p.query = append(p.query, r)
+				p.filter()
+			}
+			input = input[size:]
+			continue
+		}
+		input = input[1:]
+	}
+	return -1, false, nil
+}
+
+// handleEscape processes the escape sequence (arrows, page up/down), returns the rest of the input
+func (p *picker) handleEscape(input []byte) []byte {
+	if len(input) < 3 || (input[1] != '[' && input[1] != 'O') {
+		return input[1:]
+	}
+	switch input[2] {
+	case 'A':
+		p.move(-1)
+	case 'B':
+		p.move(1)
+	case '5', '6': // Page Up, Page Down: ESC [ 5 ~
+		if input[2] == '5' {
+			p.move(-10)
+		} else {
+			p.move(10)
+		}
+		if len(input) > 3 && input[3] == '~' {
+			return input[4:]
+		}
+	}
+	return input[3:]
+}
+
+// move moves the selection by delta
+func (p *picker) move(delta int) {
+	p.selected = max(0, min(len(p.visible)-1, p.selected+delta))
+}
+
+// filter updates the visible items using the query: items are grouped by the group,
+// groups are sorted by their best item, items within a group - by the rank (fuzzy match + frecency), then by name
+func (p *picker) filter() {
+	query := []rune(strings.ToLower(string(p.query)))
+	ranks := map[int]float64{}
+	best := map[string]float64{}
+	p.visible = p.visible[:0]
+	for i, item := range p.items {
+		score, ok := fuzzyScore(query, item)
+		if !ok {
+			continue
+		}
+		rank := float64(score) + min(item.Score, 1000)/50
+		ranks[i] = rank
+		if prev, ok := best[item.Group]; !ok || rank > prev {
+			best[item.Group] = rank
+		}
+		p.visible = append(p.visible, i)
+	}
+
+	sort.SliceStable(p.visible, func(a, b int) bool {
+		ia, ib := p.items[p.visible[a]], p.items[p.visible[b]]
+		if ia.Group != ib.Group {
+			if best[ia.Group] != best[ib.Group] {
+				return best[ia.Group] > best[ib.Group]
+			}
+			return ia.Group < ib.Group
+		}
+		if ranks[p.visible[a]] != ranks[p.visible[b]] {
+			return ranks[p.visible[a]] > ranks[p.visible[b]]
+		}
+		return ia.Name < ib.Name
+	})
+	p.selected = 0
+	p.offset = 0
+}
+
+// fuzzyScore returns the fuzzy match score of the query against the item name,
+// or (with lower score) against the item name, address, group and user
+func fuzzyScore(query []rune, item Item) (int, bool) {
+	if len(query) == 0 {
+		return 0, true
+	}
+	if score, ok := fuzzyMatch(query, []rune(strings.ToLower(item.Name))); ok {
+		return score, true
+	}
+	all := strings.ToLower(strings.Join([]string{item.Name, item.Address, item.Group, item.User}, " "))
+	if score, ok := fuzzyMatch(query, []rune(all)); ok {
+		return score / 2, true
+	}
+	return 0, false
+}
+
+// fuzzyMatch returns the score of the query characters found within the target in the same order,
+// consecutive matches and matches at the word start score higher
+func fuzzyMatch(query, target []rune) (int, bool) {
+	var score, qi int
+	prev := -2
+	for ti, r := range target {
+		if qi == len(query) {
+			break
+		}
+		if r != query[qi] {
+			continue
+		}
+		score++
+		if ti == prev+1 {
+			score += 5
+		}
+		if ti == 0 || !unicode.IsLetter(target[ti-1]) && !unicode.IsDigit(target[ti-1]) {
+			score += 3
+		}
+		prev = ti
+		qi++
+	}
+	if qi < len(query) {
+		return 0, false
+	}
+	return score, true
+}
@@ -0,0 +1,123 @@
+package picker
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	styleReset    = "\x1b[0m"
+	styleBold     = "\x1b[1m"
+	styleDim      = "\x1b[2m"
+	styleReverse  = "\x1b[7m"
+	headerLines   = 2 // query and status lines
+	noGroupHeader = "(no group)"
+)
+
+// row is a single line of the list: the group header, or the item
+type row struct {
+	header string
+	item   int // index within visible, -1 for headers
+}
+
+// rows returns the list lines: group headers followed by their items
+func (p *picker) rows() []row {
+	rows := []row{}
+	group := "\x00"
+	for i, idx := range p.visible {
+		if item := p.items[idx]; item.Group != group {
+			group = item.Group
+			header := group
+			if header == "" {
+				header = noGroupHeader
+			}
+			rows = append(rows, row{header: header, item: -1})
+		}
+		rows = append(rows, row{item: i})
+	}
+	return rows
+}
+
+// render draws the picker (the terminal is in the raw mode, so lines end with \r\n)
+func (p *picker) render(width, height int) {
+	var b strings.Builder
+	b.WriteString("\x1b[H")
+	writeLine(&b, "> "+string(p.query), width, "")
+	status := fmt.Sprintf("%d/%d hosts, up/down: move, enter: connect, esc: quit", len(p.visible), len(p.items))
+	writeLine(&b, status, width, styleDim)
+
+	rows := p.rows()
+	selectedRow := 0
+	for i, r := range rows {
+		if r.item == p.selected {
+			selectedRow = i
+		}
+	}
+	listHeight := max(1, height-headerLines-1) // the last line is left empty to avoid scrolling
+	if selectedRow < p.offset {
+		p.offset = selectedRow
+	}
+	if selectedRow >= p.offset+listHeight {
+		p.offset = selectedRow - listHeight + 1
+	}
+	if p.offset > 0 && selectedRow > 0 && rows[selectedRow-1].item == -1 && selectedRow-1 < p.offset {
+		p.offset = selectedRow - 1 // keep the group header of the selected item visible
+	}
+
+	nameWidth, addrWidth := p.columnWidths()
+	for i := p.offset; i < len(rows) && i < p.offset+listHeight; i++ {
+		r := rows[i]
+		if r.item == -1 {
+			writeLine(&b, "["+r.header+"]", width, styleBold)
+			continue
+		}
+		item := p.items[p.visible[r.item]]
+		line := fmt.Sprintf("  %-*s  %-*s  %s", nameWidth, item.Name, addrWidth, item.Address, userPort(item))
+		style := ""
+		if r.item == p.selected {
+			style = styleReverse
+		}
+		writeLine(&b, line, width, style)
+	}
+	b.WriteString("\x1b[J")                                                  // clear the rest of the screen
+	fmt.Fprintf(&b, "\x1b[1;%dH", utf8.RuneCountInString(string(p.query))+3) // cursor at the end of the query
+	p.out.Write([]byte(b.String()))                                          //nolint:errcheck // nothing to do with the error here
+}
+
+// columnWidths returns the widths of the name and address columns
+func (p *picker) columnWidths() (nameWidth, addrWidth int) {
+	for _, idx := range p.visible {
+		nameWidth = max(nameWidth, utf8.RuneCountInString(p.items[idx].Name))
+		addrWidth = max(addrWidth, utf8.RuneCountInString(p.items[idx].Address))
+	}
+	return nameWidth, addrWidth
+}
+
+// userPort returns the "user, port" column value
+func userPort(item Item) string {
+	parts := []string{}
+	if item.User != "" {
+		parts = append(parts, "user: "+item.User)
+	}
+	if item.Port != 0 {
+		parts = append(parts, "port: "+strconv.Itoa(item.Port))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// writeLine writes the line truncated to the width, with optional style
+func writeLine(b *strings.Builder, line string, width int, style string) {
+	if utf8.RuneCountInString(line) > width {
+		line = string([]rune(line)[:width])
+	}
+	if style != "" {
+		b.WriteString(style)
+		b.WriteString(line)
+		b.WriteString(styleReset)
+	} else {
+		b.WriteString(line)
+	}
+	b.WriteString("\x1b[K\r\n")
+}
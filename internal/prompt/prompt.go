@@ -0,0 +1,60 @@
+package prompt
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/etkecc/ansible-ssh/internal/term"
+)
+
+var (
+	// ErrNotInteractive is returned when stdin is not a terminal, so the user cannot be asked
+	ErrNotInteractive = errors.New("stdin is not a terminal")
+	// ErrCanceled is returned when the user did not pick anything
+	ErrCanceled = errors.New("canceled")
+)
+
+// IsInteractive returns true if stdin is a terminal
+func IsInteractive() bool {
+	return term.IsTerminal(os.Stdin.Fd())
+}
+
+// Choose asks the user to pick one of the items using the numbered list, returns the picked item index
+func Choose(question string, items []string) (int, error) {
+	if !IsInteractive() {
+		return -1, ErrNotInteractive
+	}
+	return choose(os.Stdin, os.Stderr, question, items)
+}
+
+func choose(in io.Reader, out io.Writer, question string, items []string) (int, error) {
+	fmt.Fprintln(out, question)
+	for i, item := range items {
+		fmt.Fprintf(out, "  %d) %s\n", i+1, item)
+	}
+
+	reader := bufio.NewReader(in)
+	for {
+		fmt.Fprintf(out, "pick [1-%d]: ", len(items))
+		line, err := reader.ReadString('\n')
+		line = strings.TrimSpace(line)
+		if line == "" && err != nil {
+			return -1, ErrCanceled
+		}
+		if line == "" {
+			continue
+		}
+		if num, convErr := strconv.Atoi(line); convErr == nil && num >= 1 && num <= len(items) {
+			return num - 1, nil
+		}
+		if err != nil {
+			return -1, ErrCanceled
+		}
+		fmt.Fprintln(out, "invalid choice:", line)
+	}
+}
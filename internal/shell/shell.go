@@ -0,0 +1,97 @@
+package shell
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrUnclosedQuote is returned when the input contains unclosed quote
+var ErrUnclosedQuote = errors.New("unclosed quote")
+
+// safeChars are the characters that do not require quoting
+const safeChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789@%+=:,./-_"
+
+// Split splits the input into words using POSIX shell rules (single and double quotes, backslash escapes),
+// an unquoted # at the beginning of a word starts a comment
+func Split(input string) ([]string, error) {
+	words := []string{}
+	var word strings.Builder
+	var inWord bool
+	var quote rune
+	var escaped, dqEscaped bool
+
+	for _, r := range input {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case dqEscaped:
+			// within double quotes, backslash escapes only $ ` " \ and newline
+			if !strings.ContainsRune("$`\"\\\n", r) {
+				word.WriteRune('\\')
+			}
+			word.WriteRune(r)
+			dqEscaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+				continue
+			}
+			word.WriteRune(r)
+		case quote == '"':
+			switch r {
+			case '"':
+				quote = 0
+			case '\\':
+				dqEscaped = true
+			default:
+				word.WriteRune(r)
+			}
+		case r == '\\':
+			escaped = true
+			inWord = true
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		case r == '#' && !inWord:
+			return words, nil
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, ErrUnclosedQuote
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+
+	return words, nil
+}
+
+// Quote quotes the word for POSIX shell, if needed
+func Quote(word string) string {
+	if word == "" {
+		return "''"
+	}
+	if strings.Trim(word, safeChars) == "" {
+		return word
+	}
+	return "'" + strings.ReplaceAll(word, "'", `'\''`) + "'"
+}
+
+// Join quotes and joins the words into a single command line
+func Join(words []string) string {
+	quoted := make([]string, 0, len(words))
+	for _, word := range words {
+		quoted = append(quoted, Quote(word))
+	}
+	return strings.Join(quoted, " ")
+}
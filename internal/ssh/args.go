@@ -0,0 +1,180 @@
+package ssh
+
+import (
+	"strconv"
+	"strings"
+)
+
+// uriPrefix is the prefix of the ssh://[user@]host[:port] destination form
+const uriPrefix = "ssh://"
+
+// flagsWithValue contains all ssh(1) options that take an argument,
+// everything else is treated as a boolean flag (-1246AaCfGgKkMNnqsTtVvXxYy)
+var flagsWithValue = map[byte]bool{
+	'B': true, // bind_interface
+	'b': true, // bind_address
+	'c': true, // cipher_spec
+	'D': true, // [bind_address:]port
+	'E': true, // log_file
+	'e': true, // escape_char
+	'F': true, // configfile
+	'I': true, // pkcs11
+	'i': true, // identity_file
+	'J': true, // destination
+	'L': true, // address
+	'l': true, // login_name
+	'm': true, // mac_spec
+	'O': true, // ctl_cmd
+	'o': true, // option
+	'P': true, // tag
+	'p': true, // port
+	'Q': true, // query_option
+	'R': true, // address
+	'S': true, // ctl_path
+	'W': true, // host:port
+	'w': true, // local_tun[:remote_tun]
+}
+
+// Args is a parsed ssh command line
+type Args struct {
+	Raw         []string // original arguments, as provided by the user
+	Options     []string // user-provided options (with their values), in the original order
+	Destination string   // destination, as provided by the user
+	Host        string   // host part of the destination
+	User        string   // user from -l, -o User or from the destination
+	Port        int      // port from -p, -o Port or from the ssh:// destination
+	Keys        []string // identity files from -i
+	Jump        string   // jump host(s) from -J or -o ProxyJump
+	Command     []string // remote command and its arguments
+	Terminated  bool     // options were terminated with "--"
+
+	portInOptions bool // port was set with -p or -o Port, so it is already a part of Options
+}
+
+// ParseArgs parses ssh command line arguments (without the program name)
+// the same way ssh(1) does: options may be placed before and after the destination,
+// the first non-option argument after the destination starts the remote command
+func ParseArgs(raw []string) *Args {
+	args := &Args{Raw: raw, Options: []string{}, Command: []string{}}
+	for i := 0; i < len(raw); i++ {
+		arg := raw[i]
+		if !args.Terminated && arg == "--" {
+			args.Terminated = true
+			continue
+		}
+		if args.Terminated || !isOption(arg) {
+			if args.Destination != "" {
+				args.Command = append(args.Command, raw[i:]...)
+				break
+			}
+			args.setDestination(arg)
+			continue
+		}
+		i = args.parseOption(raw, i)
+	}
+
+	return args
+}
+
+// parseOption parses the option cluster at raw[idx] (e.g. "-At", "-p22", "-p 22")
+// and returns index of the last consumed argument
+func (a *Args) parseOption(raw []string, idx int) int {
+	arg := raw[idx]
+	a.Options = append(a.Options, arg)
+	for j := 1; j < len(arg); j++ {
+		flag := arg[j]
+		if !flagsWithValue[flag] {
+			continue
+		}
+
+		value := arg[j+1:]
+		if value == "" && idx+1 < len(raw) {
+			idx++
+			value = raw[idx]
+			a.Options = append(a.Options, value)
+		}
+		a.setOption(flag, value)
+		break
+	}
+
+	return idx
+}
+
+func (a *Args) setOption(flag byte, value string) {
+	switch flag {
+	case 'l':
+		if a.User == "" {
+			a.User = value
+		}
+	case 'p':
+		if port, err := strconv.Atoi(value); err == nil && !a.portInOptions {
+			a.Port = port
+			a.portInOptions = true
+		}
+	case 'i':
+		a.Keys = append(a.Keys, value)
+	case 'J':
+		if a.Jump == "" {
+			a.Jump = value
+		}
+	case 'o':
+		key, optValue, ok := strings.Cut(value, "=")
+		if !ok {
+			key, optValue, _ = strings.Cut(value, " ")
+		}
+		switch optValue = strings.TrimSpace(optValue); strings.ToLower(strings.TrimSpace(key)) {
+		case "proxyjump":
+			a.setOption('J', optValue)
+		case "port":
+			a.setOption('p', optValue)
+		case "user":
+			a.setOption('l', optValue)
+		}
+	}
+}
+
+func (a *Args) setDestination(dest string) {
+	a.Destination = dest
+	user, host, port := parseDestination(dest)
+	a.Host = host
+	// ssh(1) uses the first obtained value, so -l and -p provided before the destination win
+	if a.User == "" {
+		a.User = user
+	}
+	if a.Port == 0 {
+		a.Port = port
+	}
+}
+
+// parseDestination parses [user@]host and ssh://[user@]host[:port] destinations
+func parseDestination(dest string) (user, host string, port int) {
+	if !strings.HasPrefix(dest, uriPrefix) {
+		if idx := strings.LastIndex(dest, "@"); idx != -1 {
+			return dest[:idx], dest[idx+1:], 0
+		}
+		return "", dest, 0
+	}
+
+	host = strings.TrimSuffix(strings.TrimPrefix(dest, uriPrefix), "/")
+	if idx := strings.LastIndex(host, "@"); idx != -1 {
+		user = host[:idx]
+		host = host[idx+1:]
+	}
+
+	var portStr string
+	if strings.HasPrefix(host, "[") {
+		if end := strings.Index(host, "]"); end != -1 {
+			portStr = strings.TrimPrefix(host[end+1:], ":")
+			host = host[1:end]
+		}
+	} else if strings.Count(host, ":") == 1 {
+		host, portStr, _ = strings.Cut(host, ":")
+	}
+	port, _ = strconv.Atoi(portStr) //nolint:errcheck // invalid port is the same as no port
+
+	return user, host, port
+}
+
+func isOption(arg string) bool {
+	return len(arg) > 1 && arg[0] == '-'
+}
@@ -0,0 +1,115 @@
+package ssh
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		expected Args
+	}{
+		{
+			"host", []string{"web01"},
+			Args{Destination: "web01", Host: "web01"},
+		},
+		{
+			"user@host", []string{"admin@web01"},
+			Args{Destination: "admin@web01", Host: "web01", User: "admin"},
+		},
+		{
+			"user with @", []string{"admin@example.com@web01"},
+			Args{Destination: "admin@example.com@web01", Host: "web01", User: "admin@example.com"},
+		},
+		{
+			"uri", []string{"ssh://admin@web01:2222"},
+			Args{Destination: "ssh://admin@web01:2222", Host: "web01", User: "admin", Port: 2222},
+		},
+		{
+			"ipv6 uri", []string{"ssh://[::1]:2222/"},
+			Args{Destination: "ssh://[::1]:2222/", Host: "::1", Port: 2222},
+		},
+		{
+			"ipv6", []string{"admin@::1"},
+			Args{Destination: "admin@::1", Host: "::1", User: "admin"},
+		},
+		{
+			"clustered flags with separate value", []string{"-vvp", "22", "web01"},
+			Args{Options: []string{"-vvp", "22"}, Destination: "web01", Host: "web01", Port: 22},
+		},
+		{
+			"attached value", []string{"-p22", "-ikey", "-At", "web01"},
+			Args{Options: []string{"-p22", "-ikey", "-At"}, Destination: "web01", Host: "web01", Port: 22, Keys: []string{"key"}},
+		},
+		{
+			"option port and user", []string{"-o", "Port=2222", "-oUser admin", "web01"},
+			Args{Options: []string{"-o", "Port=2222", "-oUser admin"}, Destination: "web01", Host: "web01", User: "admin", Port: 2222},
+		},
+		{
+			"the first port wins", []string{"-p", "2200", "-o", "Port=2222", "ssh://web01:2233"},
+			Args{Options: []string{"-p", "2200", "-o", "Port=2222"}, Destination: "ssh://web01:2233", Host: "web01", Port: 2200},
+		},
+		{
+			"-l wins over the destination user", []string{"-l", "root", "admin@web01"},
+			Args{Options: []string{"-l", "root"}, Destination: "admin@web01", Host: "web01", User: "root"},
+		},
+		{
+			"jump", []string{"-J", "bastion", "web01"},
+			Args{Options: []string{"-J", "bastion"}, Destination: "web01", Host: "web01", Jump: "bastion"},
+		},
+		{
+			"proxy jump option", []string{"-o", "proxyjump = admin@bastion:2222", "web01"},
+			Args{Options: []string{"-o", "proxyjump = admin@bastion:2222"}, Destination: "web01", Host: "web01", Jump: "admin@bastion:2222"},
+		},
+		{
+			"options after the destination", []string{"web01", "-t", "-p", "2222", "uptime", "-p"},
+			Args{Options: []string{"-t", "-p", "2222"}, Destination: "web01", Host: "web01", Port: 2222, Command: []string{"uptime", "-p"}},
+		},
+		{
+			"command", []string{"web01", "ls", "-la", "/"},
+			Args{Destination: "web01", Host: "web01", Command: []string{"ls", "-la", "/"}},
+		},
+		{
+			"terminated", []string{"-t", "--", "web01", "-la"},
+			Args{Options: []string{"-t"}, Destination: "web01", Host: "web01", Command: []string{"-la"}, Terminated: true},
+		},
+		{
+			"terminated after the destination", []string{"web01", "--", "ls", "--", "-la"},
+			Args{Destination: "web01", Host: "web01", Command: []string{"ls", "--", "-la"}, Terminated: true},
+		},
+		{
+			"no destination", []string{"-V"},
+			Args{Options: []string{"-V"}},
+		},
+		{
+			"value at the end", []string{"web01", "-p"},
+			Args{Options: []string{"-p"}, Destination: "web01", Host: "web01"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			args := ParseArgs(test.args)
+			expected := test.expected
+			if !slices.Equal(args.Raw, test.args) {
+				t.Errorf("expected raw args %q, got %q", test.args, args.Raw)
+			}
+			if !slices.Equal(args.Options, append([]string{}, expected.Options...)) {
+				t.Errorf("expected options %q, got %q", expected.Options, args.Options)
+			}
+			if !slices.Equal(args.Command, append([]string{}, expected.Command...)) {
+				t.Errorf("expected command %q, got %q", expected.Command, args.Command)
+			}
+			if !slices.Equal(args.Keys, expected.Keys) {
+				t.Errorf("expected keys %q, got %q", expected.Keys, args.Keys)
+			}
+			if args.Destination != expected.Destination || args.Host != expected.Host || args.User != expected.User ||
+				args.Port != expected.Port || args.Jump != expected.Jump || args.Terminated != expected.Terminated {
+				t.Errorf("expected destination %q, host %q, user %q, port %d, jump %q, terminated %t, got %q, %q, %q, %d, %q, %t",
+					expected.Destination, expected.Host, expected.User, expected.Port, expected.Jump, expected.Terminated,
+					args.Destination, args.Host, args.User, args.Port, args.Jump, args.Terminated)
+			}
+		})
+	}
+}
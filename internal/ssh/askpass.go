@@ -0,0 +1,279 @@
+package ssh
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/etkecc/ansible-ssh/internal/logger"
+	"github.com/etkecc/ansible-ssh/internal/term"
+	"github.com/etkecc/go-ansible"
+)
+
+const (
+	// askpassName is the name of the symlink to the ansible-ssh binary, set as SSH_ASKPASS,
+	// ansible-ssh started under that name becomes the askpass helper
+	askpassName = "ansible-ssh-askpass"
+	// askpassSocketEnv is the env var with the askpass server socket path, set for the ssh command
+	askpassSocketEnv = "ANSIBLE_SSH_ASKPASS_SOCKET"
+	// askpassTokenEnv is the env var with the token of the host password
+	askpassTokenEnv = "ANSIBLE_SSH_ASKPASS_TOKEN"
+	// askpassTimeout is the max time of the helper-server exchange
+	askpassTimeout = 5 * time.Second
+)
+
+// printPasswords makes ansible-ssh print the ssh and become passwords before connecting
+var printPasswords bool
+
+// SetPrintPasswords enables or disables printing of the passwords before connecting
+func SetPrintPasswords(enabled bool) {
+	printPasswords = enabled
+}
+
+// logPasswords prints the passwords of the host, if enabled
+func logPasswords(host *ansible.Host) {
+	if !printPasswords || host == nil {
+		return
+	}
+	if host.SSHPass != "" {
+		logger.Println("ssh password of", host.Name, "is:", host.SSHPass)
+	}
+	if host.BecomePass != "" && host.User != "root" {
+		logger.Println("become password of", host.Name, "is:", host.BecomePass)
+	}
+}
+
+// askpassServer passes the host passwords to the askpass helpers over the unix socket within the private temp dir.
+// Every password is served once, so the retries after a wrong password are asked from the terminal
+type askpassServer struct {
+	dir       string
+	helper    string // path of the askpass symlink
+	listener  net.Listener
+	mu        sync.Mutex
+	passwords map[string]string // token -> password
+	served    chan struct{}     // receives a value every time the password is served
+}
+
+// newAskpassServer starts the askpass server
+func newAskpassServer() (*askpassServer, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	dir, err := os.MkdirTemp("", "ansible-ssh-askpass-")
+	if err != nil {
+		return nil, err
+	}
+	helper := filepath.Join(dir, askpassName)
+	if err := os.Symlink(executable, helper); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	listener, err := net.Listen("unix", filepath.Join(dir, "socket"))
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	a := &askpassServer{dir: dir, helper: helper, listener: listener, passwords: map[string]string{}, served: make(chan struct{}, 64)}
+	go a.serve()
+	return a, nil
+}
+
+// withAskpass returns the env vars that make ssh get the host password from the askpass server,
+// nil if the host has no password or the server cannot be started
+func withAskpass(a **askpassServer, host *ansible.Host) []string {
+	if host == nil || host.SSHPass == "" || dryRun.Enabled {
+		return nil
+	}
+	if *a == nil {
+		server, err := newAskpassServer()
+		if err != nil {
+			logger.Println("cannot start the askpass server, the ssh password will not be entered automatically:", err)
+			return nil
+		}
+		*a = server
+	}
+	return (*a).env(host.SSHPass)
+}
+
+// env registers the password and returns the env vars for the ssh command
+func (a *askpassServer) env(password string) []string {
+	token := make([]byte, 16)
+	rand.Read(token) //nolint:errcheck // crypto/rand.Read never returns an error
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.passwords[hex.EncodeToString(token)] = password
+	return []string{
+		"SSH_ASKPASS=" + a.helper,
+		"SSH_ASKPASS_REQUIRE=force",
+		askpassSocketEnv + "=" + a.listener.Addr().String(),
+		askpassTokenEnv + "=" + hex.EncodeToString(token),
+	}
+}
+
+// serve answers the helpers: the token goes in, the password (once) goes out
+func (a *askpassServer) serve() {
+	for {
+		conn, err := a.listener.Accept()
+		if err != nil {
+			return
+		}
+		go func(conn net.Conn) {
+			defer conn.Close()
+			conn.SetDeadline(time.Now().Add(askpassTimeout)) //nolint:errcheck // nothing to do with the error here
+			token, err := bufio.NewReader(conn).ReadString('\n')
+			if err != nil {
+				return
+			}
+			a.mu.Lock()
+			password, ok := a.passwords[strings.TrimSpace(token)]
+			delete(a.passwords, strings.TrimSpace(token))
+			a.mu.Unlock()
+			if !ok {
+				return
+			}
+			io.WriteString(conn, password) //nolint:errcheck // nothing to do with the error here
+			select {
+			case a.served <- struct{}{}:
+			default:
+			}
+		}(conn)
+	}
+}
+
+// wait waits until all registered passwords have been served, or the timeout
+func (a *askpassServer) wait(timeout time.Duration) {
+	deadline := time.After(timeout)
+	for {
+		a.mu.Lock()
+		pending := len(a.passwords)
+		a.mu.Unlock()
+		if pending == 0 {
+			return
+		}
+		select {
+		case <-a.served:
+		case <-deadline:
+			return
+		}
+	}
+}
+
+// close stops the server and removes the socket
+func (a *askpassServer) close() {
+	if a == nil {
+		return
+	}
+	a.listener.Close()
+	os.RemoveAll(a.dir)
+}
+
+// IsAskpass returns true if ansible-ssh has been started by ssh as the SSH_ASKPASS helper (by the program name)
+func IsAskpass(program string) bool {
+	return strings.TrimSuffix(filepath.Base(program), ".exe") == askpassName
+}
+
+// RunAskpass implements the SSH_ASKPASS helper: the password prompt is answered with the host password from the parent ansible-ssh process,
+// any other prompt (host key confirmation, key passphrase, retries after a wrong password) is asked from the terminal
+func RunAskpass(args []string) error {
+	var prompt string
+	if len(args) > 0 {
+		prompt = args[0]
+	}
+	switch os.Getenv("SSH_ASKPASS_PROMPT") {
+	case "confirm":
+		answer, err := askTerminal(prompt+" (yes/no) ", true)
+		if err != nil {
+			return err
+		}
+		if answer := strings.ToLower(strings.TrimSpace(answer)); answer != "yes" && answer != "y" {
+			return errors.New("not confirmed")
+		}
+		return nil
+	case "none":
+		_, err := fmt.Fprintln(os.Stderr, prompt)
+		return err
+	}
+
+	if strings.Contains(strings.ToLower(prompt), "password") {
+		if password, err := askServer(); err == nil && password != "" {
+			_, err = fmt.Println(password)
+			return err
+		}
+	}
+	lowerPrompt := strings.ToLower(prompt)
+	echo := strings.Contains(lowerPrompt, "yes/no") || strings.Contains(lowerPrompt, "(yes")
+	answer, err := askTerminal(prompt, echo)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Println(answer)
+	return err
+}
+
+// askServer gets the password from the parent ansible-ssh process
+func askServer() (string, error) {
+	conn, err := net.DialTimeout("unix", os.Getenv(askpassSocketEnv), askpassTimeout)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(askpassTimeout)) //nolint:errcheck // nothing to do with the error here
+	if _, err := io.WriteString(conn, os.Getenv(askpassTokenEnv)+"\n"); err != nil {
+		return "", err
+	}
+	password, err := io.ReadAll(conn)
+	return string(password), err
+}
+
+// askTerminal asks the user on the controlling terminal, without echo unless requested
+func askTerminal(prompt string, echo bool) (string, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return "", err
+	}
+	defer tty.Close()
+	if _, err := io.WriteString(tty, prompt); err != nil {
+		return "", err
+	}
+	if echo {
+		answer, err := bufio.NewReader(tty).ReadString('\n')
+		return strings.TrimRight(answer, "\r\n"), err
+	}
+
+	state, err := term.MakeRaw(tty.Fd())
+	if err != nil {
+		return "", err
+	}
+	defer term.Restore(tty.Fd(), state) //nolint:errcheck // nothing to do with the error here
+	defer io.WriteString(tty, "\r\n")   //nolint:errcheck // nothing to do with the error here
+
+	var answer []byte
+	buf := make([]byte, 1)
+	for {
+		if _, err := tty.Read(buf); err != nil {
+			return "", err
+		}
+		switch buf[0] {
+		case '\r', '\n':
+			return string(answer), nil
+		case 3, 4: // Ctrl+C, Ctrl+D
+			return "", errors.New("canceled")
+		case 127, 8: // Backspace
+			if len(answer) > 0 {
+				answer = answer[:len(answer)-1]
+			}
+		default:
+			answer = append(answer, buf[0])
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package ssh
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/etkecc/go-ansible"
+)
+
+// setEnv sets the KEY=VALUE env vars for the test
+func setEnv(t *testing.T, env []string) {
+	t.Helper()
+	for _, item := range env {
+		key, value, _ := strings.Cut(item, "=")
+		t.Setenv(key, value)
+	}
+}
+
+func TestAskpassExchange(t *testing.T) {
+	server, err := newAskpassServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.close)
+
+	env := server.env("P@ss w0rd")
+	setEnv(t, env)
+	if helper := os.Getenv("SSH_ASKPASS"); !IsAskpass(helper) || !strings.HasPrefix(helper, server.dir) {
+		t.Errorf("unexpected SSH_ASKPASS %q", helper)
+	}
+	if os.Getenv("SSH_ASKPASS_REQUIRE") != "force" {
+		t.Errorf("expected SSH_ASKPASS_REQUIRE=force, got %q", os.Getenv("SSH_ASKPASS_REQUIRE"))
+	}
+
+	tests := []struct {
+		name     string
+		token    string
+		expected string
+	}{
+		{"first ask", os.Getenv(askpassTokenEnv), "P@ss w0rd"},
+		// the empty answer makes RunAskpass ask the terminal, so the retries after a wrong password are up to the user
+		{"second ask", os.Getenv(askpassTokenEnv), ""},
+		{"unknown token", strings.Repeat("0", 32), ""},
+		{"empty token", "", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv(askpassTokenEnv, test.token)
+			password, err := askServer()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if password != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, password)
+			}
+		})
+	}
+}
+
+func TestAskpassWait(t *testing.T) {
+	server, err := newAskpassServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.close)
+
+	start := time.Now()
+	server.wait(time.Minute)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected no wait without the passwords, waited %s", elapsed)
+	}
+
+	setEnv(t, server.env("served"))
+	go askServer() //nolint:errcheck // checked by the wait below
+	start = time.Now()
+	server.wait(time.Minute)
+	if elapsed := time.Since(start); elapsed > askpassTimeout {
+		t.Errorf("expected the wait to end once the password is served, waited %s", elapsed)
+	}
+
+	server.env("never served")
+	start = time.Now()
+	server.wait(200 * time.Millisecond)
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond || elapsed > time.Second {
+		t.Errorf("expected the wait to time out after 200ms, waited %s", elapsed)
+	}
+}
+
+func TestAskpassClose(t *testing.T) {
+	server, err := newAskpassServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	setEnv(t, server.env("secret"))
+
+	server.close()
+	if _, err := os.Stat(server.dir); !os.IsNotExist(err) {
+		t.Errorf("expected %s to be removed, got %v", server.dir, err)
+	}
+	if _, err := askServer(); err == nil {
+		t.Error("expected the closed server to refuse the helper")
+	}
+	(*askpassServer)(nil).close() // must not panic
+}
+
+func TestWithAskpass(t *testing.T) {
+	tests := []struct {
+		name     string
+		host     *ansible.Host
+		dryRun   bool
+		expected bool
+	}{
+		{"password", &ansible.Host{Name: "web01", SSHPass: "secret"}, false, true},
+		{"no password", &ansible.Host{Name: "web01"}, false, false},
+		{"no host", nil, false, false},
+		{"dry run", &ansible.Host{Name: "web01", SSHPass: "secret"}, true, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			SetDryRun(DryRun{Enabled: test.dryRun})
+			t.Cleanup(func() { SetDryRun(DryRun{}) })
+			var server *askpassServer
+			t.Cleanup(func() { server.close() })
+
+			env := withAskpass(&server, test.host)
+			if (env != nil) != test.expected || (server != nil) != test.expected {
+				t.Errorf("expected the askpass env: %t, got %q", test.expected, env)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package ssh
+
+import (
+	"github.com/etkecc/ansible-ssh/internal/broadcast"
+	"github.com/etkecc/go-ansible"
+)
+
+// Broadcast opens the ssh sessions to the hosts within the built-in broadcast terminal, each running the same ssh command line as Run.
+// In the dry-run mode, the commands are printed instead
+func Broadcast(sshCmd string, hosts []*ansible.Host, environ []string) error {
+	if dryRun.Enabled {
+		for _, host := range hosts {
+			Run(sshCmd, host, ParseArgs([]string{host.Name}), false, environ)
+		}
+		return nil
+	}
+
+	var askpass *askpassServer
+	defer func() { askpass.close() }() // the server is started lazily
+	targets := make([]broadcast.Target, 0, len(hosts))
+	for _, host := range hosts {
+		logPasswords(host)
+		cmd, err := buildCMD(sshCmd, host, ParseArgs([]string{host.Name}), false)
+		if err != nil {
+			return err
+		}
+		argv := cmd.Args
+		makeControlPathDir(argv, host)
+		targets = append(targets, broadcast.Target{
+			Name: host.Name,
+			Argv: argv,
+			Env:  withAskpass(&askpass, host),
+		})
+	}
+	return broadcast.Run(targets, environ)
+}
@@ -0,0 +1,165 @@
+package ssh
+
+import (
+	"strings"
+
+	"github.com/etkecc/go-ansible"
+)
+
+// configFlags maps ssh(1) boolean flags onto the ssh_config(5) options
+var configFlags = map[byte][]string{
+	'4': {"AddressFamily inet"},
+	'6': {"AddressFamily inet6"},
+	'A': {"ForwardAgent yes"},
+	'a': {"ForwardAgent no"},
+	'C': {"Compression yes"},
+	'g': {"GatewayPorts yes"},
+	'K': {"GSSAPIAuthentication yes", "GSSAPIDelegateCredentials yes"},
+	'k': {"GSSAPIDelegateCredentials no"},
+	'M': {"ControlMaster yes"},
+	'q': {"LogLevel QUIET"},
+	'T': {"RequestTTY no"},
+	't': {"RequestTTY yes"},
+	'v': {"LogLevel DEBUG"},
+	'X': {"ForwardX11 yes"},
+	'x': {"ForwardX11 no"},
+	'Y': {"ForwardX11 yes", "ForwardX11Trusted yes"},
+}
+
+// configValueFlags maps ssh(1) flags with value onto the ssh_config(5) options
+var configValueFlags = map[byte]string{
+	'B': "BindInterface",
+	'b': "BindAddress",
+	'c': "Ciphers",
+	'D': "DynamicForward",
+	'e': "EscapeChar",
+	'I': "PKCS11Provider",
+	'i': "IdentityFile",
+	'J': "ProxyJump",
+	'L': "LocalForward",
+	'l': "User",
+	'm': "MACs",
+	'P': "Tag",
+	'p': "Port",
+	'R': "RemoteForward",
+	'S': "ControlPath",
+}
+
+// configPathOptions are the ssh_config(5) options with a single path value, it must be quoted if it contains spaces
+var configPathOptions = map[string]bool{
+	"CertificateFile":     true,
+	"ControlPath":         true,
+	"IdentityAgent":       true,
+	"IdentityFile":        true,
+	"PKCS11Provider":      true,
+	"RevokedHostKeys":     true,
+	"SecurityKeyProvider": true,
+	"XAuthLocation":       true,
+}
+
+// Config returns the ssh_config(5) Host blocks of the hosts (see ConfigBlock), each one is preceded by the inventory file comment.
+// ssh merges the options of all blocks that match the host name, so if the same name is defined within several inventories,
+// only the first host is written, the same one that is picked by the host lookup
+func Config(hosts []*ansible.Host) string {
+	var b strings.Builder
+	written := map[string]string{} // host name -> inventory file
+	for _, host := range hosts {
+		invFile := host.Vars.String("inventory_file")
+		if first, ok := written[host.Name]; ok {
+			b.WriteString("\n# " + host.Name + " from " + invFile + " is skipped, it is already defined in " + first + "\n")
+			continue
+		}
+		written[host.Name] = invFile
+		b.WriteString("\n# " + invFile + "\n")
+		b.WriteString(ConfigBlock(host))
+	}
+	return b.String()
+}
+
+// ConfigBlock returns the ssh_config(5) Host block of the host, with the same options as the ssh command line built by Run:
+// HostName, Port, User, IdentityFile, ProxyJump and the options from the ssh args vars.
+// Command line flags that have no ssh_config equivalent are kept as comments
+func ConfigBlock(host *ansible.Host) string {
+	var b strings.Builder
+	b.WriteString("Host " + host.Name + "\n")
+	if host.Vars.String("ansible_connection") == "local" {
+		b.WriteString("    # ansible_connection=local is not supported by ssh\n")
+		return b.String()
+	}
+
+	b.WriteString("    HostName " + host.Host + "\n")
+	sshArgs := buildArgs(nil, ParseArgs([]string{host.Name}), host)
+	if len(sshArgs) > 0 {
+		sshArgs = sshArgs[:len(sshArgs)-1] // the destination
+	}
+	if host.User != "" {
+		b.WriteString("    User " + host.User + "\n")
+	}
+	for _, option := range configOptions(sshArgs) {
+		b.WriteString("    " + quoteConfigOption(option) + "\n")
+	}
+	return b.String()
+}
+
+// quoteConfigOption quotes the path value of the ssh_config option if it contains spaces
+func quoteConfigOption(option string) string {
+	key, value, ok := strings.Cut(option, " ")
+	if !ok || !configPathOptions[key] || !strings.ContainsAny(value, " \t") || strings.HasPrefix(value, `"`) {
+		return option
+	}
+	return key + ` "` + value + `"`
+}
+
+// configOptions converts ssh(1) command line options into ssh_config(5) options
+func configOptions(sshArgs []string) []string {
+	options := []string{}
+	for i := 0; i < len(sshArgs); i++ {
+		arg := sshArgs[i]
+		if !isOption(arg) || arg == "--" {
+			options = append(options, "# unsupported argument: "+arg)
+			continue
+		}
+		for j := 1; j < len(arg); j++ {
+			flag := arg[j]
+			if !flagsWithValue[flag] {
+				if flagOptions, ok := configFlags[flag]; ok {
+					options = append(options, flagOptions...)
+				} else {
+					options = append(options, "# unsupported flag: -"+string(flag))
+				}
+				continue
+			}
+
+			value := arg[j+1:]
+			if value == "" && i+1 < len(sshArgs) {
+				i++
+				value = sshArgs[i]
+			}
+			options = append(options, configOption(flag, value))
+			break
+		}
+	}
+	return options
+}
+
+// configOption converts the ssh(1) flag with value into the ssh_config(5) option
+func configOption(flag byte, value string) string {
+	if flag == 'o' {
+		key, optValue, ok := strings.Cut(value, "=")
+		if !ok {
+			key, optValue, _ = strings.Cut(value, " ")
+		}
+		return strings.TrimSpace(key) + " " + strings.TrimSpace(optValue)
+	}
+
+	name, ok := configValueFlags[flag]
+	if !ok {
+		return "# unsupported flag: -" + string(flag) + " " + value
+	}
+	if flag == 'L' || flag == 'R' { // [bind_address:]port:host:hostport -> [bind_address:]port host:hostport
+		if parts := strings.Split(value, ":"); len(parts) >= 3 {
+			value = strings.Join(parts[:len(parts)-2], ":") + " " + strings.Join(parts[len(parts)-2:], ":")
+		}
+	}
+	return name + " " + value
+}
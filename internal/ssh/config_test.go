@@ -0,0 +1,59 @@
+package ssh
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/etkecc/go-ansible"
+)
+
+func TestConfigBlock(t *testing.T) {
+	tests := []struct {
+		name     string
+		host     *ansible.Host
+		expected string
+	}{
+		{
+			name:     "inventory values",
+			host:     &ansible.Host{Name: "web01", Host: "10.0.0.1", Port: 2222, User: "deploy", PrivateKeys: []string{"/keys/web01"}},
+			expected: "Host web01\n    HostName 10.0.0.1\n    User deploy\n    IdentityFile /keys/web01\n    Port 2222\n",
+		},
+		{
+			name:     "paths with spaces",
+			host:     &ansible.Host{Name: "web01", Host: "10.0.0.1", PrivateKeys: []string{"/my keys/web01"}, Vars: ansible.HostVars{"ansible_ssh_common_args": "-o IdentityAgent='/run/my agent.sock' -o ProxyCommand='nc -X 5 %h %p'"}},
+			expected: "Host web01\n    HostName 10.0.0.1\n    IdentityAgent \"/run/my agent.sock\"\n    ProxyCommand nc -X 5 %h %p\n    IdentityFile \"/my keys/web01\"\n",
+		},
+		{
+			name:     "local connection",
+			host:     &ansible.Host{Name: "localhost", Vars: ansible.HostVars{"ansible_connection": "local"}},
+			expected: "Host localhost\n    # ansible_connection=local is not supported by ssh\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if block := ConfigBlock(test.host); block != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, block)
+			}
+		})
+	}
+}
+
+func TestConfigDuplicates(t *testing.T) {
+	hosts := []*ansible.Host{
+		{Name: "web01", Host: "10.0.0.1", Vars: ansible.HostVars{"inventory_file": "/project/hosts"}},
+		{Name: "db01", Host: "10.0.1.1", Vars: ansible.HostVars{"inventory_file": "/project/hosts"}},
+		{Name: "web01", Host: "192.0.2.1", Vars: ansible.HostVars{"inventory_file": "/customer/hosts"}},
+	}
+	config := Config(hosts)
+	if count := strings.Count(config, "Host web01\n"); count != 1 {
+		t.Errorf("expected one web01 block, got %d within %q", count, config)
+	}
+	for _, expected := range []string{"HostName 10.0.0.1\n", "Host db01\n", "# web01 from /customer/hosts is skipped, it is already defined in /project/hosts\n"} {
+		if !strings.Contains(config, expected) {
+			t.Errorf("expected %q within %q", expected, config)
+		}
+	}
+	if strings.Contains(config, "192.0.2.1") {
+		t.Errorf("expected the duplicate to be skipped, got %q", config)
+	}
+}
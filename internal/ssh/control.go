@@ -0,0 +1,115 @@
+package ssh
+
+import (
+	"crypto/sha1" //nolint:gosec // used for the control path naming only, the same as ansible does
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/etkecc/ansible-ssh/internal/logger"
+	"github.com/etkecc/go-ansible"
+)
+
+// defaultControlPathDir is the default ansible control_path_dir
+const defaultControlPathDir = "~/.ansible/cp"
+
+// controlPathArgs returns the ControlPath option if ControlPersist is enabled and ControlPath is not set yet,
+// the control path is named the same way as in the ansible ssh connection plugin, so the multiplexed connections are shared with ansible runs
+func controlPathArgs(sshArgs []string, host *ansible.Host, port int, user string) []string {
+	var persist bool
+	for _, arg := range sshArgs {
+		arg = strings.ToLower(arg)
+		if strings.Contains(arg, "controlpath") {
+			return nil
+		}
+		if strings.Contains(arg, "controlpersist") {
+			persist = true
+		}
+	}
+	if !persist {
+		return nil
+	}
+
+	dir := controlPathDir(host)
+	controlPath := host.Vars.String("ansible_control_path")
+	if controlPath == "" {
+		controlPath = "%(directory)s/" + controlPathHash(host.Host, port, user)
+	}
+	controlPath = strings.ReplaceAll(controlPath, "%(directory)s", dir)
+
+	return []string{"-o", "ControlPath=\"" + controlPath + "\""}
+}
+
+// controlPathDir returns the absolute control path dir of the host
+func controlPathDir(host *ansible.Host) string {
+	dir := host.Vars.String("ansible_control_path_dir", defaultControlPathDir)
+	if dir == "~" || strings.HasPrefix(dir, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			dir = filepath.Join(home, strings.TrimPrefix(dir, "~"))
+		}
+	}
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	return dir
+}
+
+// makeControlPathDir creates the control path dir of the host if the command uses it (see controlPathArgs),
+// it is called right before the command is run, so the dry-run, explain and export do not create anything
+func makeControlPathDir(argv []string, host *ansible.Host) {
+	if host == nil {
+		return
+	}
+	dir := controlPathDir(host)
+	uses := slices.ContainsFunc(argv, func(arg string) bool {
+		return strings.Contains(arg, "ControlPath=") && strings.Contains(arg, dir)
+	})
+	if !uses {
+		return
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		logger.Println("cannot create control path dir", dir, "error:", err)
+	}
+}
+
+// controlPathPort returns the port used within the control path hash: the user-provided port or the configured port (see ConfiguredPort).
+// The ansible-ssh config default port is not used, because ansible does not know about it and would name the control path differently
+func controlPathPort(host *ansible.Host, args *Args) int {
+	if args.Port != 0 {
+		return args.Port
+	}
+	return ConfiguredPort(host)
+}
+
+// ConfiguredPort returns the port configured for the host by the port vars (inventory vars, ansible.cfg remote_port),
+// 0 if the port is not set or is set by the ansible-ssh config defaults only
+func ConfiguredPort(host *ansible.Host) int {
+	for _, name := range []string{"ansible_ssh_port", "ansible_port"} { // the last alias wins
+		value, ok := host.Vars[name]
+		if !ok {
+			continue
+		}
+		if port, err := strconv.Atoi(fmt.Sprint(value)); err == nil {
+			return port
+		}
+		return host.Port // templated value, already rendered
+	}
+	return 0
+}
+
+// controlPathHash returns the hash of the connection attributes, the same as ansible's _create_control_path
+func controlPathHash(host string, port int, user string) string {
+	portStr := "None"
+	if port != 0 {
+		portStr = strconv.Itoa(port)
+	}
+	if user == "" {
+		user = "None"
+	}
+	hash := sha1.Sum([]byte(host + "-" + portStr + "-" + user)) //nolint:gosec // see above
+	return hex.EncodeToString(hash[:])[:10]
+}
@@ -0,0 +1,122 @@
+package ssh
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/etkecc/ansible-ssh/internal/shell"
+	"github.com/etkecc/go-ansible"
+)
+
+// redacted replaces the secret values within the dry-run output
+const redacted = "<redacted>"
+
+// secretEnvKeys are the parts of the env var names that hold secrets, e.g. SSHPASS or VAULT_TOKEN
+var secretEnvKeys = []string{"PASS", "SECRET", "TOKEN"}
+
+// DryRun makes Run print the command instead of executing it
+type DryRun struct {
+	Enabled       bool
+	JSON          bool // print JSON instead of the shell-quoted command line
+	ShowPasswords bool // do not redact passwords
+}
+
+// dryRun is the dry-run mode, disabled by default
+var dryRun DryRun
+
+// SetDryRun enables or disables the dry-run mode
+func SetDryRun(mode DryRun) {
+	dryRun = mode
+}
+
+// dryRunCommand is the dry-run output in the JSON format
+type dryRunCommand struct {
+	Argv           []string `json:"argv"`
+	Env            []string `json:"env"`
+	SSHPassword    string   `json:"ssh_password,omitempty"`
+	BecomePassword string   `json:"become_password,omitempty"`
+}
+
+// printCommand prints the command and the added env vars, passwords are redacted unless requested
+func printCommand(cmd *exec.Cmd, host *ansible.Host, environ []string) error {
+	out := dryRunCommand{Argv: cmd.Args, Env: environ}
+	if host != nil {
+		out.SSHPassword = host.SSHPass
+		out.BecomePassword = host.BecomePass
+	}
+	if !dryRun.ShowPasswords {
+		out = redactCommand(out)
+	}
+
+	if dryRun.JSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.SetEscapeHTML(false)
+		return encoder.Encode(out)
+	}
+
+	var b strings.Builder
+	if out.SSHPassword != "" {
+		fmt.Fprintf(&b, "# ssh password: %s\n", out.SSHPassword)
+	}
+	if out.BecomePassword != "" {
+		fmt.Fprintf(&b, "# become password: %s\n", out.BecomePassword)
+	}
+	for _, env := range out.Env {
+		key, value, _ := strings.Cut(env, "=")
+		b.WriteString(key + "=" + shell.Quote(value) + " ")
+	}
+	b.WriteString(shell.Join(out.Argv) + "\n")
+	_, err := io.WriteString(os.Stdout, b.String())
+	return err
+}
+
+// redactCommand replaces the passwords and the values of the secret env vars with the placeholder
+func redactCommand(out dryRunCommand) dryRunCommand {
+	secrets := []string{}
+	for _, password := range []string{out.SSHPassword, out.BecomePassword} {
+		if password != "" {
+			secrets = append(secrets, password)
+		}
+	}
+	redact := func(value string) string {
+		for _, secret := range secrets {
+			value = strings.ReplaceAll(value, secret, redacted)
+		}
+		return value
+	}
+
+	redactedOut := dryRunCommand{Argv: make([]string, 0, len(out.Argv)), Env: make([]string, 0, len(out.Env))}
+	for _, arg := range out.Argv {
+		redactedOut.Argv = append(redactedOut.Argv, redact(arg))
+	}
+	for _, env := range out.Env {
+		key, value, _ := strings.Cut(env, "=")
+		if isSecretEnv(key) {
+			value = redacted
+		}
+		redactedOut.Env = append(redactedOut.Env, key+"="+redact(value))
+	}
+	if out.SSHPassword != "" {
+		redactedOut.SSHPassword = redacted
+	}
+	if out.BecomePassword != "" {
+		redactedOut.BecomePassword = redacted
+	}
+	return redactedOut
+}
+
+// isSecretEnv returns true if the env var name looks like it holds a secret
+func isSecretEnv(key string) bool {
+	upper := strings.ToUpper(key)
+	for _, part := range secretEnvKeys {
+		if strings.Contains(upper, part) {
+			return true
+		}
+	}
+	return false
+}
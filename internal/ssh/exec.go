@@ -0,0 +1,201 @@
+package ssh
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/etkecc/ansible-ssh/internal/logger"
+	"github.com/etkecc/go-ansible"
+)
+
+// execWaitDelay is the time to wait for the output pipes to close after the command exits,
+// e.g. a ControlPersist master process keeps them open
+const execWaitDelay = 2 * time.Second
+
+// ErrTimeout is the error of the command that has been killed by timeout
+var ErrTimeout = errors.New("timeout")
+
+// ExecOptions configures Exec
+type ExecOptions struct {
+	Forks   int           // max number of hosts to run the command on in parallel
+	Timeout time.Duration // per-host timeout, 0 disables it
+	Collect bool          // print the output of each host at once when the command finishes, instead of the prefixed lines
+	Stdout  io.Writer     // default: os.Stdout
+	Stderr  io.Writer     // default: os.Stderr
+}
+
+// ExecResult is the result of the command executed on the host
+type ExecResult struct {
+	Host     *ansible.Host
+	ExitCode int // -1 if the command has not been started or has been killed
+	Duration time.Duration
+	Err      error // start error or ErrTimeout
+}
+
+// OK returns true if the command succeeded
+func (r ExecResult) OK() bool {
+	return r.Err == nil && r.ExitCode == 0
+}
+
+// Exec runs the non-interactive command on every host (at most opts.Forks hosts at a time),
+// the output lines are prefixed with the host names, or collected and printed per host
+func Exec(sshCmd string, hosts []*ansible.Host, command []string, opts ExecOptions, environ []string) []ExecResult {
+	forks := opts.Forks
+	if forks <= 0 {
+		forks = 1
+	}
+	if opts.Stdout == nil {
+		opts.Stdout = os.Stdout
+	}
+	if opts.Stderr == nil {
+		opts.Stderr = os.Stderr
+	}
+
+	var mu sync.Mutex // guards stdout and stderr
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, forks)
+	results := make([]ExecResult, len(hosts))
+	var askpass *askpassServer
+	defer func() { askpass.close() }() // the server is started lazily
+	for i, host := range hosts {
+		cmd, err := buildCMD(sshCmd, host, ParseArgs(execArgs(host, command)), false)
+		if err != nil {
+			if dryRun.Enabled {
+				logger.Println(err)
+			}
+			results[i] = ExecResult{Host: host, ExitCode: -1, Err: err}
+			continue
+		}
+		if dryRun.Enabled {
+			if err := printCommand(cmd, host, environ); err != nil {
+				logger.Fatal("cannot print the command:", err)
+			}
+			results[i] = ExecResult{Host: host}
+			continue
+		}
+
+		makeControlPathDir(cmd.Args, host)
+		hostEnv := slices.Concat(environ, withAskpass(&askpass, host))
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int, host *ansible.Host, cmd *exec.Cmd) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			results[i] = execHost(cmd, host, opts, hostEnv, &mu)
+		}(i, host, cmd)
+	}
+	wg.Wait()
+	return results
+}
+
+// execArgs returns the ssh arguments of the non-interactive command
+func execArgs(host *ansible.Host, command []string) []string {
+	raw := []string{"-T", "-o", "BatchMode=yes", host.Name, "--"}
+	if host.SSHPass != "" { // BatchMode disables the password prompts, the password is entered by the askpass helper instead
+		raw = []string{"-T", host.Name, "--"}
+	}
+	return append(raw, command...)
+}
+
+// execHost runs the command of the host
+func execHost(cmd *exec.Cmd, host *ansible.Host, opts ExecOptions, environ []string, mu *sync.Mutex) ExecResult {
+	result := ExecResult{Host: host, ExitCode: -1}
+	var collected bytes.Buffer
+	stdout := &prefixWriter{w: opts.Stdout, prefix: host.Name + " | ", mu: mu}
+	stderr := &prefixWriter{w: opts.Stderr, prefix: host.Name + " | ", mu: mu}
+	if opts.Collect {
+		cmd.Stdout, cmd.Stderr = &collected, &collected
+	} else {
+		cmd.Stdout, cmd.Stderr = stdout, stderr
+	}
+	cmd.Env = append(os.Environ(), environ...)
+	cmd.WaitDelay = execWaitDelay
+
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		result.Err = err
+		return result
+	}
+	var timedOut bool
+	if opts.Timeout > 0 {
+		timer := time.AfterFunc(opts.Timeout, func() {
+			mu.Lock()
+			timedOut = true
+			mu.Unlock()
+			cmd.Process.Kill() //nolint:errcheck // nothing to do with the error here
+		})
+		defer timer.Stop()
+	}
+	err := cmd.Wait()
+	result.Duration = time.Since(start)
+	stdout.Flush()
+	stderr.Flush()
+
+	var exitErr *exec.ExitError
+	mu.Lock()
+	switch {
+	case timedOut:
+		result.Err = ErrTimeout
+	case err == nil:
+		result.ExitCode = 0
+	case errors.As(err, &exitErr):
+		result.ExitCode = exitErr.ExitCode()
+	default:
+		result.Err = err
+	}
+	if opts.Collect {
+		io.WriteString(opts.Stdout, "==> "+host.Name+" <==\n") //nolint:errcheck // nothing to do with the error here
+		opts.Stdout.Write(collected.Bytes())                   //nolint:errcheck // nothing to do with the error here
+		if collected.Len() > 0 && !bytes.HasSuffix(collected.Bytes(), []byte("\n")) {
+			io.WriteString(opts.Stdout, "\n") //nolint:errcheck // nothing to do with the error here
+		}
+	}
+	mu.Unlock()
+	return result
+}
+
+// prefixWriter writes the complete lines with the prefix, the writes are guarded by the mutex
+type prefixWriter struct {
+	w      io.Writer
+	prefix string
+	mu     *sync.Mutex
+	buf    []byte
+}
+
+// Write implements io.Writer
+func (p *prefixWriter) Write(data []byte) (int, error) {
+	p.buf = append(p.buf, data...)
+	idx := bytes.LastIndexByte(p.buf, '\n')
+	if idx == -1 {
+		return len(data), nil
+	}
+	lines := p.buf[:idx+1]
+	p.buf = append([]byte{}, p.buf[idx+1:]...)
+
+	var out bytes.Buffer
+	for _, line := range bytes.SplitAfter(lines, []byte("\n")) {
+		if len(line) > 0 {
+			out.WriteString(p.prefix)
+			out.Write(line)
+		}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, err := p.w.Write(out.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+// Flush writes the incomplete last line, if any
+func (p *prefixWriter) Flush() {
+	if len(p.buf) > 0 {
+		p.Write([]byte("\n")) //nolint:errcheck // nothing to do with the error here
+	}
+}
@@ -0,0 +1,231 @@
+package ssh
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/etkecc/go-ansible"
+)
+
+// fakeSSH is the fake ssh command: it skips the options, and behaves depending on the destination:
+// fail* hosts exit with code 3, slow* hosts sleep, everything else prints the destination and the command.
+// The number of the concurrently running commands is recorded into the $STATE/max file
+const fakeSSH = `#!/bin/sh
+while [ "$1" != "--" ]; do shift; done
+shift; dest=$1; shift
+touch "$STATE/running.$$"
+ls "$STATE" | grep -c running >> "$STATE/max"
+trap 'rm -f "$STATE/running.$$"' EXIT
+case $dest in
+  fail*) echo "failing on $dest" >&2; exit 3;;
+  slow*) sleep 30;;
+esac
+sleep 0.2
+printf '%s: %s\nno newline' "$dest" "$*"
+`
+
+// writeFakeSSH creates the fake ssh command and its state dir, returns the command path
+func writeFakeSSH(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	state := filepath.Join(dir, "state")
+	if err := os.Mkdir(state, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("STATE", state)
+	sshCmd := filepath.Join(dir, "ssh")
+	if err := os.WriteFile(sshCmd, []byte(fakeSSH), 0o700); err != nil { //nolint:gosec // the script must be executable
+		t.Fatal(err)
+	}
+	return sshCmd
+}
+
+// maxRunning returns the max number of the concurrently running fake ssh commands
+func maxRunning(t *testing.T) int {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(os.Getenv("STATE"), "max"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	highest := 0
+	for _, line := range strings.Fields(string(data)) {
+		if n, err := strconv.Atoi(line); err == nil && n > highest {
+			highest = n
+		}
+	}
+	return highest
+}
+
+// testHosts returns the hosts with the names and addresses
+func testHosts(names ...string) []*ansible.Host {
+	hosts := make([]*ansible.Host, 0, len(names))
+	for _, name := range names {
+		hosts = append(hosts, &ansible.Host{Name: name, Host: name})
+	}
+	return hosts
+}
+
+func TestExecForks(t *testing.T) {
+	tests := []struct {
+		forks    int
+		expected int // max number of the concurrent commands
+	}{
+		{0, 1},
+		{1, 1},
+		{2, 2},
+		{10, 6},
+	}
+	for _, test := range tests {
+		t.Run(strconv.Itoa(test.forks), func(t *testing.T) {
+			sshCmd := writeFakeSSH(t)
+			hosts := testHosts("web01", "web02", "web03", "web04", "web05", "web06")
+			var stdout, stderr bytes.Buffer
+			results := Exec(sshCmd, hosts, []string{"uptime"}, ExecOptions{Forks: test.forks, Stdout: &stdout, Stderr: &stderr}, nil)
+
+			if running := maxRunning(t); running > test.expected || (test.expected > 1 && running < 2) {
+				t.Errorf("expected at most %d concurrent commands, got %d", test.expected, running)
+			}
+			for i, result := range results {
+				if result.Host != hosts[i] || !result.OK() {
+					t.Errorf("expected %s to succeed, got %+v", hosts[i].Name, result)
+				}
+				for _, line := range []string{"web0" + strconv.Itoa(i+1) + " | web0" + strconv.Itoa(i+1) + ": uptime\n", "web0" + strconv.Itoa(i+1) + " | no newline\n"} {
+					if !strings.Contains(stdout.String(), line) {
+						t.Errorf("expected %q within the output %q", line, stdout.String())
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestExecResults(t *testing.T) {
+	sshCmd := writeFakeSSH(t)
+	hosts := testHosts("web01", "fail01", "slow01")
+	var stdout, stderr bytes.Buffer
+	start := time.Now()
+	results := Exec(sshCmd, hosts, []string{"uptime"}, ExecOptions{Forks: 3, Timeout: 500 * time.Millisecond, Stdout: &stdout, Stderr: &stderr}, nil)
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond+execWaitDelay+2*time.Second {
+		t.Errorf("expected the slow host to be killed after the timeout, took %s", elapsed)
+	}
+
+	tests := []struct {
+		host     string
+		exitCode int
+		err      error
+	}{
+		{"web01", 0, nil},
+		{"fail01", 3, nil},
+		{"slow01", -1, ErrTimeout},
+	}
+	for i, test := range tests {
+		t.Run(test.host, func(t *testing.T) {
+			result := results[i]
+			if result.Host.Name != test.host || result.ExitCode != test.exitCode || !errors.Is(result.Err, test.err) {
+				t.Errorf("expected %s exit code %d and error %v, got %+v", test.host, test.exitCode, test.err, result)
+			}
+			if result.OK() != (test.exitCode == 0 && test.err == nil) {
+				t.Errorf("unexpected OK() %t", result.OK())
+			}
+		})
+	}
+	if !strings.Contains(stderr.String(), "fail01 | failing on fail01\n") {
+		t.Errorf("expected the prefixed stderr, got %q", stderr.String())
+	}
+}
+
+func TestExecCollect(t *testing.T) {
+	sshCmd := writeFakeSSH(t)
+	var stdout bytes.Buffer
+	Exec(sshCmd, testHosts("web01", "fail01"), []string{"echo", "ok"}, ExecOptions{Forks: 2, Collect: true, Stdout: &stdout}, nil)
+
+	for _, block := range []string{"==> web01 <==\nweb01: echo ok\nno newline\n", "==> fail01 <==\nfailing on fail01\n"} {
+		if !strings.Contains(stdout.String(), block) {
+			t.Errorf("expected %q within the output %q", block, stdout.String())
+		}
+	}
+}
+
+func TestExecStartError(t *testing.T) {
+	sshCmd := writeFakeSSH(t)
+	unsupported := &ansible.Host{Name: "win01", Host: "10.0.0.9", Vars: ansible.HostVars{"ansible_connection": "winrm"}}
+	tests := []struct {
+		name   string
+		sshCmd string
+		hosts  []*ansible.Host
+	}{
+		{"missing command", filepath.Join(t.TempDir(), "missing"), testHosts("web01")},
+		{"unsupported connection", sshCmd, append(testHosts("web01"), unsupported)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			results := Exec(test.sshCmd, test.hosts, []string{"uptime"}, ExecOptions{Stdout: &stdout}, nil)
+			last := results[len(results)-1]
+			if len(results) != len(test.hosts) || last.Err == nil || last.OK() {
+				t.Errorf("expected the error of the last host, got %+v", results)
+			}
+			if len(results) > 1 && !results[0].OK() {
+				t.Errorf("expected the other hosts to run, got %+v", results[0])
+			}
+		})
+	}
+}
+
+func TestPrefixWriter(t *testing.T) {
+	tests := []struct {
+		name     string
+		writes   []string
+		expected string
+	}{
+		{"lines", []string{"a\nb\n"}, "> a\n> b\n"},
+		{"split line", []string{"a", "b\nc", "\n"}, "> ab\n> c\n"},
+		{"incomplete", []string{"a\nb"}, "> a\n> b\n"},
+		{"empty lines", []string{"\n\n"}, "> \n> \n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out bytes.Buffer
+			w := &prefixWriter{w: &out, prefix: "> ", mu: new(sync.Mutex)}
+			for _, data := range test.writes {
+				if _, err := w.Write([]byte(data)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			w.Flush()
+			if out.String() != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, out.String())
+			}
+		})
+	}
+}
+
+func TestExecArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		host     *ansible.Host
+		expected []string
+	}{
+		{"batch mode", &ansible.Host{Name: "web01", Host: "10.0.0.1"}, []string{"ssh", "-T", "-o", "BatchMode=yes", "--", "10.0.0.1", "uptime"}},
+		{"password", &ansible.Host{Name: "web01", Host: "10.0.0.1", SSHPass: "secret"}, []string{"ssh", "-T", "--", "10.0.0.1", "uptime"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cmd, err := buildCMD("ssh", test.host, ParseArgs(execArgs(test.host, []string{"uptime"})), false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(cmd.Args, test.expected) {
+				t.Errorf("expected %q, got %q", test.expected, cmd.Args)
+			}
+		})
+	}
+}
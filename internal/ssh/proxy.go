@@ -0,0 +1,95 @@
+package ssh
+
+import (
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/etkecc/go-ansible"
+)
+
+// proxyDialTimeout is the timeout for the proxy connection
+const proxyDialTimeout = 30 * time.Second
+
+// jumpVars are the host vars that may contain the jump host (-J or -o ProxyJump)
+var jumpVars = []string{"ansible_ssh_common_args", "ansible_ssh_extra_args"}
+
+// Proxy dials the address and splices the connection with stdin and stdout,
+// intended to be used as ssh ProxyCommand
+func Proxy(address string, stdin io.Reader, stdout io.Writer) error {
+	conn, err := net.DialTimeout("tcp", address, proxyDialTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return Splice(conn, stdin, stdout)
+}
+
+// Splice copies stdin to the connection and the connection to stdout.
+// When stdin is exhausted, the write side of the connection is closed (if supported),
+// and the function returns when the remote side closes the connection
+func Splice(conn io.ReadWriter, stdin io.Reader, stdout io.Writer) error {
+	inErr := make(chan error, 1)
+	go func() {
+		_, err := io.Copy(conn, stdin)
+		if cw, ok := conn.(interface{ CloseWrite() error }); ok {
+			err = errors.Join(err, cw.CloseWrite())
+		}
+		inErr <- err
+	}()
+
+	_, err := io.Copy(stdout, conn)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case err = <-inErr:
+		return err
+	default: // remote side closed the connection, stdin is not relevant anymore
+		return nil
+	}
+}
+
+// ProxyJumpArgs returns ssh arguments that forward stdio to the address through the jump host(s)
+// configured in the host vars, or nil if the host has no jump host
+func ProxyJumpArgs(host *ansible.Host, address string) *Args {
+	if host == nil {
+		return nil
+	}
+
+	var jump string
+	for _, key := range jumpVars {
+		if jump = ParseArgs(hostArgs(host, key)).Jump; jump != "" {
+			break
+		}
+	}
+	if jump == "" || strings.EqualFold(jump, "none") {
+		return nil
+	}
+
+	raw := []string{"-W", address}
+	hops := strings.Split(jump, ",")
+	if len(hops) > 1 {
+		raw = append(raw, "-J", strings.Join(hops[:len(hops)-1], ","))
+	}
+	raw = append(raw, jumpDestination(hops[len(hops)-1]))
+
+	return ParseArgs(raw)
+}
+
+// jumpDestination returns the ssh destination of the jump host: [user@]host[:port] is converted into ssh://[user@]host:port,
+// because ssh does not accept the port within the plain destination
+func jumpDestination(hop string) string {
+	_, address, ok := strings.Cut(hop, "@")
+	if !ok {
+		address = hop
+	}
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		return hop
+	}
+	return "ssh://" + hop
+}
@@ -0,0 +1,118 @@
+package ssh
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/etkecc/go-ansible"
+)
+
+// echoServer starts the TCP server that sends back everything it receives, and returns its address
+func echoServer(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn) //nolint:errcheck // nothing to do with the error here
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func TestProxy(t *testing.T) {
+	address := echoServer(t)
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"empty", ""},
+		{"line", "SSH-2.0-OpenSSH_9.6\r\n"},
+		{"large", strings.Repeat("0123456789abcdef", 64*1024)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := Proxy(address, strings.NewReader(test.input), &out); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != test.input {
+				t.Errorf("expected %d bytes echoed back, got %d", len(test.input), out.Len())
+			}
+		})
+	}
+}
+
+func TestProxyDialError(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	if err := Proxy(address, strings.NewReader("data"), io.Discard); err == nil {
+		t.Error("expected the dial error")
+	}
+}
+
+func TestSpliceRemoteClose(t *testing.T) {
+	local, remote := net.Pipe()
+	go func() {
+		remote.Write([]byte("bye")) //nolint:errcheck // nothing to do with the error here
+		remote.Close()
+	}()
+
+	// stdin never ends, the remote side closing the connection must finish the splice
+	stdin, _ := io.Pipe()
+	var out bytes.Buffer
+	if err := Splice(local, stdin, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "bye" {
+		t.Errorf("expected bye, got %q", out.String())
+	}
+}
+
+func TestProxyJumpArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		vars     ansible.HostVars
+		expected []string // nil if no jump host
+	}{
+		{"no jump host", ansible.HostVars{}, nil},
+		{"none", ansible.HostVars{"ansible_ssh_common_args": "-o ProxyJump=none"}, nil},
+		{"common args", ansible.HostVars{"ansible_ssh_common_args": "-o ProxyJump=bastion"}, []string{"-W", "10.0.0.1:22", "bastion"}},
+		{"extra args", ansible.HostVars{"ansible_ssh_extra_args": "-J admin@bastion:2222"}, []string{"-W", "10.0.0.1:22", "ssh://admin@bastion:2222"}},
+		{"ipv6 jump host with port", ansible.HostVars{"ansible_ssh_extra_args": "-J [2001:db8::1]:2222"}, []string{"-W", "10.0.0.1:22", "ssh://[2001:db8::1]:2222"}},
+		{"chain with port", ansible.HostVars{"ansible_ssh_common_args": "-J first:2200,second:2222"}, []string{"-W", "10.0.0.1:22", "-J", "first:2200", "ssh://second:2222"}},
+		{"chain", ansible.HostVars{"ansible_ssh_common_args": "-J first,second,third"}, []string{"-W", "10.0.0.1:22", "-J", "first,second", "third"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			args := ProxyJumpArgs(&ansible.Host{Name: "web01", Host: "10.0.0.1", Vars: test.vars}, "10.0.0.1:22")
+			switch {
+			case test.expected == nil && args != nil:
+				t.Errorf("expected no jump host, got %q", args.Raw)
+			case test.expected != nil && (args == nil || !slices.Equal(args.Raw, test.expected)):
+				t.Errorf("expected %q, got %+v", test.expected, args)
+			case test.name == "extra args" && (args.Host != "bastion" || args.Port != 2222 || args.User != "admin"):
+				t.Errorf("expected admin@bastion port 2222, got %+v", args)
+			}
+		})
+	}
+}
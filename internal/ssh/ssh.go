@@ -2,12 +2,14 @@ package ssh
 
 import (
 	"errors"
+	"fmt"
 	"os"
 	"os/exec"
 	"strconv"
 	"strings"
 
 	"github.com/etkecc/ansible-ssh/internal/logger"
+	"github.com/etkecc/ansible-ssh/internal/shell"
 	"github.com/etkecc/go-ansible"
 )
 
@@ -16,20 +18,39 @@ var legitExitCode = map[int]bool{
 	130: true, // Ctrl+C
 }
 
-// Run executes the ssh command
-func Run(sshCmd string, host *ansible.Host, strict bool, environ []string) {
-	cmd := buildCMD(sshCmd, host, strict)
+// Run executes the ssh command, or prints it in the dry-run mode
+func Run(sshCmd string, host *ansible.Host, args *Args, strict bool, environ []string) {
+	cmd, err := buildCMD(sshCmd, host, args, strict)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	execute(cmd, host, environ)
+}
+
+// execute runs the command (ssh or a tool that uses ssh, e.g. scp) connecting to the host, or prints it in the dry-run mode
+func execute(cmd *exec.Cmd, host *ansible.Host, environ []string) {
+	if dryRun.Enabled {
+		if err := printCommand(cmd, host, environ); err != nil {
+			logger.Fatal("cannot print the command:", err)
+		}
+		return
+	}
+	logPasswords(host)
+	makeControlPathDir(cmd.Args, host)
+	var askpass *askpassServer
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	cmd.Stdin = os.Stdin
 	env := append(os.Environ(), environ...)
-	cmd.Env = env
+	cmd.Env = append(env, withAskpass(&askpass, host)...)
 
 	err := cmd.Start()
 	if err != nil {
+		askpass.close()
 		logger.Fatal("cannot start the command:", err)
 	}
 	err = cmd.Wait()
+	askpass.close()
 	if err != nil {
 		var exitErr *exec.ExitError
 		if errors.As(err, &exitErr) && legitExitCode[exitErr.ExitCode()] {
@@ -39,61 +60,142 @@ func Run(sshCmd string, host *ansible.Host, strict bool, environ []string) {
 	}
 }
 
-func buildCMD(sshCmd string, host *ansible.Host, strict bool) *exec.Cmd {
-	osArgs := os.Args[1:]
-	sshArgs := make([]string, 0)
-	parts := strings.Split(sshCmd, " ")
-	if len(parts) > 1 {
-		sshCmd = parts[0]
-		sshArgs = parts[1:]
+// buildCMD returns the command that connects to the host, an error if the host cannot be connected to
+// (not found within inventory in the strict mode, or uses an unsupported connection)
+func buildCMD(sshCmd string, host *ansible.Host, args *Args, strict bool) (*exec.Cmd, error) {
+	sshCmd, sshArgs := splitCommand(sshCmd)
+	if host != nil {
+		if executable := host.Vars.String("ansible_ssh_executable"); executable != "" {
+			sshCmd = executable
+		}
+		localCmd, err := buildLocalCMD(host, args)
+		if err != nil || localCmd != nil {
+			return localCmd, err
+		}
 	}
 
 	if host == nil {
 		if strict {
-			logger.Fatal("host not found within inventory")
+			return nil, errors.New("host not found within inventory")
 		}
-		sshArgs = append(sshArgs, osArgs...)
+		sshArgs = append(sshArgs, args.Raw...)
 		logger.Debug("command:", sshCmd, sshArgs)
-		return exec.Command(sshCmd, sshArgs...)
+		return exec.Command(sshCmd, sshArgs...), nil
 	}
 
-	logger.Debug("command:", sshCmd, buildArgs(sshArgs, osArgs, host))
-
-	if host.SSHPass != "" {
-		logger.Println("ssh password is:", host.SSHPass)
-	}
+	sshArgs = buildArgs(sshArgs, args, host)
+	logger.Debug("command:", sshCmd, sshArgs)
+	return exec.Command(sshCmd, sshArgs...), nil //nolint:gosec // that's intended
+}
 
-	if host.BecomePass != "" && host.User != "root" {
-		logger.Println("become password is:", host.BecomePass)
+// Command returns the command line (program and arguments) that Run would execute, without executing it
+func Command(sshCmd string, host *ansible.Host, args *Args, strict bool) ([]string, error) {
+	cmd, err := buildCMD(sshCmd, host, args, strict)
+	if err != nil {
+		return nil, err
 	}
-	return exec.Command(sshCmd, buildArgs(sshArgs, osArgs, host)...) //nolint:gosec // that's intended
+	return cmd.Args, nil
 }
 
-func buildArgs(sshArgs, osArgs []string, host *ansible.Host) []string {
+// buildArgs builds ssh arguments: user-provided options go first,
+// then the inventory options that were not overridden by the user (in the same order as the ansible ssh connection plugin),
+// then the destination and the remote command
+func buildArgs(sshArgs []string, args *Args, host *ansible.Host) []string {
 	if host == nil {
 		return nil
 	}
 	if sshArgs == nil {
 		sshArgs = make([]string, 0)
 	}
+	port := args.Port
+	if port == 0 {
+		port = host.Port
+	}
+	user := args.User
+	if user == "" {
+		user = host.User
+	}
+
+	sshArgs = append(sshArgs, args.Options...)
+	sshArgs = append(sshArgs, hostArgs(host, "ansible_ssh_args")...)
+	if !host.Vars.Yes(true, "ansible_host_key_checking") {
+		sshArgs = append(sshArgs, "-o", "StrictHostKeyChecking=no")
+	}
+	if timeout := host.Vars.String("ansible_ssh_timeout"); timeout != "" {
+		sshArgs = append(sshArgs, "-o", "ConnectTimeout="+timeout)
+	}
+	sshArgs = append(sshArgs, controlPathArgs(sshArgs, host, controlPathPort(host, args), user)...)
+	sshArgs = append(sshArgs, hostArgs(host, "ansible_ssh_common_args")...)
+	sshArgs = append(sshArgs, hostArgs(host, "ansible_ssh_extra_args")...)
 
-	if len(host.PrivateKeys) > 0 {
+	if len(args.Keys) == 0 {
 		for _, key := range host.PrivateKeys {
 			sshArgs = append(sshArgs, "-i", key)
 		}
 	}
 
-	if host.Port != 0 {
+	switch {
+	case args.Port != 0 && !args.portInOptions: // port from the ssh:// destination
+		sshArgs = append(sshArgs, "-p", strconv.Itoa(args.Port))
+	case args.Port == 0 && host.Port != 0:
 		sshArgs = append(sshArgs, "-p", strconv.Itoa(host.Port))
 	}
 
-	if host.User != "" {
-		sshArgs = append(sshArgs, host.User+"@"+host.Host)
+	destination := host.Host
+	if user != "" {
+		destination = user + "@" + destination
 	}
-
-	if len(osArgs) > 1 {
-		sshArgs = append(sshArgs, osArgs[1:]...)
+	if args.Terminated || (len(args.Command) > 0 && isOption(args.Command[0])) {
+		sshArgs = append(sshArgs, "--")
 	}
+	sshArgs = append(sshArgs, destination)
+	sshArgs = append(sshArgs, args.Command...)
 
 	return sshArgs
 }
+
+// splitCommand splits the configured ssh command into the program and its arguments
+func splitCommand(sshCmd string) (program string, args []string) {
+	parts, err := shell.Split(sshCmd)
+	if err != nil || len(parts) == 0 {
+		logger.Fatal("cannot parse the ssh command", sshCmd, "error:", err)
+	}
+	return parts[0], parts[1:]
+}
+
+// hostArgs returns shell-split arguments from the host var
+func hostArgs(host *ansible.Host, key string) []string {
+	value := host.Vars.String(key)
+	if value == "" {
+		return nil
+	}
+	args, err := shell.Split(value)
+	if err != nil {
+		logger.Println("cannot parse", key, "of", host.Name, "error:", err)
+		return nil
+	}
+	return args
+}
+
+// buildLocalCMD returns the local shell command for hosts with ansible_connection=local,
+// nil for ssh-based connections, and an error for unsupported connection types
+func buildLocalCMD(host *ansible.Host, args *Args) (*exec.Cmd, error) {
+	connection := host.Vars.String("ansible_connection")
+	switch connection {
+	case "", "ssh", "smart", "paramiko", "paramiko_ssh":
+		return nil, nil
+	case "local":
+		sh := os.Getenv("SHELL")
+		if sh == "" {
+			sh = "/bin/sh"
+		}
+		if len(args.Command) == 0 {
+			logger.Debug("command:", sh, "(ansible_connection=local)")
+			return exec.Command(sh, "-l"), nil //nolint:gosec // that's intended
+		}
+		logger.Debug("command:", sh, "-c", args.Command, "(ansible_connection=local)")
+		return exec.Command(sh, "-c", strings.Join(args.Command, " ")), nil //nolint:gosec // that's intended
+	default:
+		return nil, fmt.Errorf("host %s uses unsupported ansible_connection: %s", host.Name, connection)
+	}
+}
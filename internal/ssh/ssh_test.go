@@ -0,0 +1,198 @@
+package ssh
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/etkecc/go-ansible"
+)
+
+func TestBuildCMD(t *testing.T) {
+	t.Setenv("SHELL", "/bin/bash")
+	cpDir := t.TempDir()
+	persist := "-o ControlMaster=auto -o ControlPersist=60s"
+	tests := []struct {
+		name     string
+		host     *ansible.Host
+		args     []string
+		expected []string
+	}{
+		{
+			name:     "inventory values",
+			host:     &ansible.Host{Name: "web01", Host: "10.0.0.1", Port: 2222, User: "deploy", PrivateKeys: []string{"/keys/web01"}},
+			args:     []string{"web01"},
+			expected: []string{"ssh", "-i", "/keys/web01", "-p", "2222", "deploy@10.0.0.1"},
+		},
+		{
+			name:     "user overrides",
+			host:     &ansible.Host{Name: "web01", Host: "10.0.0.1", Port: 2222, User: "deploy", PrivateKeys: []string{"/keys/web01"}},
+			args:     []string{"-p", "22", "-l", "root", "-i", "/keys/mine", "web01", "uptime"},
+			expected: []string{"ssh", "-p", "22", "-l", "root", "-i", "/keys/mine", "root@10.0.0.1", "uptime"},
+		},
+		{
+			name:     "ssh:// destination",
+			host:     &ansible.Host{Name: "web01", Host: "10.0.0.1", Port: 2222, User: "deploy"},
+			args:     []string{"ssh://admin@web01:2200"},
+			expected: []string{"ssh", "-p", "2200", "admin@10.0.0.1"},
+		},
+		{
+			name:     "host key checking and timeout",
+			host:     &ansible.Host{Name: "web01", Host: "10.0.0.1", Vars: ansible.HostVars{"ansible_host_key_checking": "false", "ansible_ssh_timeout": "15"}},
+			args:     []string{"web01"},
+			expected: []string{"ssh", "-o", "StrictHostKeyChecking=no", "-o", "ConnectTimeout=15", "10.0.0.1"},
+		},
+		{
+			name:     "host key checking enabled",
+			host:     &ansible.Host{Name: "web01", Host: "10.0.0.1", Vars: ansible.HostVars{"ansible_host_key_checking": "yes"}},
+			args:     []string{"web01"},
+			expected: []string{"ssh", "10.0.0.1"},
+		},
+		{
+			name: "control path",
+			host: &ansible.Host{Name: "web01", Host: "10.0.0.1", User: "deploy", Vars: ansible.HostVars{"ansible_ssh_args": persist, "ansible_control_path_dir": cpDir}},
+			args: []string{"web01"},
+			expected: []string{
+				"ssh", "-o", "ControlMaster=auto", "-o", "ControlPersist=60s",
+				"-o", `ControlPath="` + filepath.Join(cpDir, controlPathHash("10.0.0.1", 0, "deploy")) + `"`, "deploy@10.0.0.1",
+			},
+		},
+		{
+			name: "control path without the inventory port",
+			host: &ansible.Host{Name: "web01", Host: "10.0.0.1", Port: 22, User: "deploy", Vars: ansible.HostVars{"ansible_ssh_args": persist, "ansible_control_path_dir": cpDir}},
+			args: []string{"web01"},
+			expected: []string{
+				"ssh", "-o", "ControlMaster=auto", "-o", "ControlPersist=60s",
+				"-o", `ControlPath="` + filepath.Join(cpDir, controlPathHash("10.0.0.1", 0, "deploy")) + `"`, "-p", "22", "deploy@10.0.0.1",
+			},
+		},
+		{
+			name: "control path with the inventory port",
+			host: &ansible.Host{Name: "web01", Host: "10.0.0.1", Port: 2222, Vars: ansible.HostVars{"ansible_ssh_args": persist, "ansible_control_path_dir": cpDir, "ansible_port": 2222}},
+			args: []string{"web01"},
+			expected: []string{
+				"ssh", "-o", "ControlMaster=auto", "-o", "ControlPersist=60s",
+				"-o", `ControlPath="` + filepath.Join(cpDir, controlPathHash("10.0.0.1", 2222, "")) + `"`, "-p", "2222", "10.0.0.1",
+			},
+		},
+		{
+			name: "control path template",
+			host: &ansible.Host{Name: "web01", Host: "10.0.0.1", Vars: ansible.HostVars{"ansible_ssh_args": persist, "ansible_control_path_dir": cpDir, "ansible_control_path": "%(directory)s/%%h"}},
+			args: []string{"web01"},
+			expected: []string{
+				"ssh", "-o", "ControlMaster=auto", "-o", "ControlPersist=60s",
+				"-o", `ControlPath="` + cpDir + `/%%h"`, "10.0.0.1",
+			},
+		},
+		{
+			name:     "user control path",
+			host:     &ansible.Host{Name: "web01", Host: "10.0.0.1", Vars: ansible.HostVars{"ansible_ssh_args": persist, "ansible_control_path_dir": cpDir}},
+			args:     []string{"-o", "ControlPath=none", "web01"},
+			expected: []string{"ssh", "-o", "ControlPath=none", "-o", "ControlMaster=auto", "-o", "ControlPersist=60s", "10.0.0.1"},
+		},
+		{
+			name:     "proxy jump",
+			host:     &ansible.Host{Name: "web01", Host: "10.0.0.1", Vars: ansible.HostVars{"ansible_ssh_common_args": "-o ProxyJump=bastion", "ansible_ssh_extra_args": "-A"}},
+			args:     []string{"web01"},
+			expected: []string{"ssh", "-o", "ProxyJump=bastion", "-A", "10.0.0.1"},
+		},
+		{
+			name:     "user proxy jump goes first",
+			host:     &ansible.Host{Name: "web01", Host: "10.0.0.1", Vars: ansible.HostVars{"ansible_ssh_common_args": "-o ProxyJump=bastion"}},
+			args:     []string{"-J", "other", "web01"},
+			expected: []string{"ssh", "-J", "other", "-o", "ProxyJump=bastion", "10.0.0.1"},
+		},
+		{
+			name:     "command starting with a dash",
+			host:     &ansible.Host{Name: "web01", Host: "10.0.0.1"},
+			args:     []string{"web01", "--", "-v"},
+			expected: []string{"ssh", "--", "10.0.0.1", "-v"},
+		},
+		{
+			name:     "ssh executable",
+			host:     &ansible.Host{Name: "web01", Host: "10.0.0.1", Vars: ansible.HostVars{"ansible_ssh_executable": "/opt/ssh"}},
+			args:     []string{"web01"},
+			expected: []string{"/opt/ssh", "10.0.0.1"},
+		},
+		{
+			name:     "local connection",
+			host:     &ansible.Host{Name: "localhost", Host: "127.0.0.1", Vars: ansible.HostVars{"ansible_connection": "local"}},
+			args:     []string{"localhost"},
+			expected: []string{"/bin/bash", "-l"},
+		},
+		{
+			name:     "local connection with command",
+			host:     &ansible.Host{Name: "localhost", Host: "127.0.0.1", Vars: ansible.HostVars{"ansible_connection": "local"}},
+			args:     []string{"localhost", "echo", "ok"},
+			expected: []string{"/bin/bash", "-c", "echo ok"},
+		},
+		{
+			name:     "unknown host",
+			args:     []string{"-p", "22", "example.com"},
+			expected: []string{"ssh", "-p", "22", "example.com"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cmd, err := buildCMD("ssh", test.host, ParseArgs(test.args), false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(cmd.Args, test.expected) {
+				t.Errorf("expected %q, got %q", test.expected, cmd.Args)
+			}
+		})
+	}
+}
+
+func TestBuildCMDErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		host   *ansible.Host
+		strict bool
+	}{
+		{"unsupported connection", &ansible.Host{Name: "win01", Host: "10.0.0.9", Vars: ansible.HostVars{"ansible_connection": "winrm"}}, false},
+		{"not found, strict", nil, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if cmd, err := buildCMD("ssh", test.host, ParseArgs([]string{"win01"}), test.strict); err == nil {
+				t.Errorf("expected an error, got %q", cmd.Args)
+			}
+		})
+	}
+}
+
+func TestMakeControlPathDir(t *testing.T) {
+	persist := "-o ControlMaster=auto -o ControlPersist=60s"
+	tests := []struct {
+		name     string
+		vars     ansible.HostVars
+		args     []string
+		expected bool
+	}{
+		{"control path", ansible.HostVars{"ansible_ssh_args": persist}, []string{"web01"}, true},
+		{"user control path", ansible.HostVars{"ansible_ssh_args": persist}, []string{"-o", "ControlPath=none", "web01"}, false},
+		{"no control persist", ansible.HostVars{}, []string{"web01"}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cpDir := filepath.Join(t.TempDir(), "cp")
+			test.vars["ansible_control_path_dir"] = cpDir
+			host := &ansible.Host{Name: "web01", Host: "10.0.0.1", Vars: test.vars}
+
+			cmd, err := buildCMD("ssh", host, ParseArgs(test.args), false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := os.Stat(cpDir); err == nil {
+				t.Fatal("the control path dir has been created before the run")
+			}
+			makeControlPathDir(cmd.Args, host)
+			if _, err := os.Stat(cpDir); (err == nil) != test.expected {
+				t.Errorf("expected the control path dir to exist: %t, got error: %v", test.expected, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package ssh
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/etkecc/ansible-ssh/internal/logger"
+	"github.com/etkecc/ansible-ssh/internal/shell"
+	"github.com/etkecc/go-ansible"
+)
+
+const (
+	// tmuxTarget is the placeholder of the session_id:window_id target, replaced with the actual target (or a shell var in the dry-run mode)
+	tmuxTarget = "\x00target"
+	// tmuxSession is the placeholder of the session_id
+	tmuxSession = "\x00session"
+	// tmuxAskpassTimeout is the max time to wait for the password prompts of the panes inside tmux
+	tmuxAskpassTimeout = time.Minute
+)
+
+// TmuxOptions configures Tmux
+type TmuxOptions struct {
+	Session string // session name
+	Layout  string // layout of the panes, e.g. tiled or even-vertical
+	Windows bool   // one window per host instead of one pane per host
+	Sync    bool   // enable synchronize-panes, so the input goes to all panes of the window
+}
+
+// Tmux opens the ssh sessions to the hosts within the tmux session: one pane (or window) per host, each running the same ssh command line as Run.
+// Inside tmux, a new window is created in the current session instead. If the session already exists, it is attached as is
+func Tmux(sshCmd string, hosts []*ansible.Host, opts TmuxOptions, environ []string) {
+	if len(hosts) == 0 {
+		return
+	}
+	if _, err := exec.LookPath("tmux"); err != nil && !dryRun.Enabled {
+		logger.Fatal("cannot find tmux:", err)
+	}
+	session := tmuxSessionName(opts.Session)
+	inside := os.Getenv("TMUX") != ""
+	if !inside && !dryRun.Enabled && exec.Command("tmux", "has-session", "-t", "="+session).Run() == nil {
+		logger.Println("tmux session", session, "already exists, attaching")
+		tmuxAttach("-t", "="+session)
+		return
+	}
+	if opts.Windows && opts.Sync {
+		logger.Println("synchronize-panes works within a window, it is ignored with one window per host")
+	}
+
+	// the askpass server must outlive the password prompts of the panes: it is closed after detaching,
+	// or, inside tmux, once all passwords have been served
+	var askpass *askpassServer
+	defer func() { askpass.close() }() // the server is started lazily
+	commands := make([]string, 0, len(hosts))
+	envs := make([][]string, 0, len(hosts))
+	for _, host := range hosts {
+		cmd, err := buildCMD(sshCmd, host, ParseArgs([]string{host.Name}), false)
+		if err != nil {
+			askpass.close()
+			logger.Fatal(err)
+		}
+		commands = append(commands, shell.Join(cmd.Args))
+		envs = append(envs, tmuxEnv(slices.Concat(environ, withAskpass(&askpass, host))))
+		if !dryRun.Enabled {
+			logPasswords(host)
+			makeControlPathDir(cmd.Args, host)
+		}
+	}
+
+	first := []string{"new-window", "-P", "-F", "#{session_id}:#{window_id}", "-n", tmuxWindowName(session, hosts[0].Name, opts.Windows)}
+	if !inside {
+		first = []string{"new-session", "-d", "-P", "-F", "#{session_id}:#{window_id}", "-s", session, "-n", tmuxWindowName(session, hosts[0].Name, opts.Windows)}
+	}
+	target := tmuxOutput(slices.Concat(first, envs[0], commands[:1])...)
+	for i, host := range hosts[1:] {
+		if opts.Windows {
+			target = tmuxOutput(slices.Concat([]string{"new-window", "-a", "-t", target, "-P", "-F", "#{session_id}:#{window_id}", "-n", host.Name}, envs[i+1], commands[i+1:i+2])...)
+			continue
+		}
+		tmuxRun(slices.Concat([]string{"split-window", "-t", target}, envs[i+1], commands[i+1:i+2])...)
+		// the layout is applied after every split, so there is enough space for the next pane
+		tmuxRun("select-layout", "-t", target, opts.Layout)
+	}
+	if opts.Sync && !opts.Windows {
+		tmuxRun("set-window-option", "-t", target, "synchronize-panes", "on")
+	}
+	if !inside {
+		tmuxAttach("-t", tmuxSessionID(target))
+		return
+	}
+	if askpass != nil {
+		logger.Println("waiting for the ssh password prompts of the panes")
+		askpass.wait(tmuxAskpassTimeout)
+	}
+}
+
+// tmuxSessionName returns the session name, tmux does not allow dots and colons within the names
+func tmuxSessionName(name string) string {
+	return strings.NewReplacer(".", "_", ":", "_").Replace(name)
+}
+
+// tmuxWindowName returns the name of the first window: the host name within the one window per host mode, the session name otherwise
+func tmuxWindowName(session, host string, windows bool) string {
+	if windows {
+		return host
+	}
+	return session
+}
+
+// tmuxEnv returns the -e flags with the env vars, so the ssh commands get them regardless of the tmux server environment
+func tmuxEnv(environ []string) []string {
+	env := make([]string, 0, len(environ)*2)
+	for _, kv := range environ {
+		if dryRun.Enabled && !dryRun.ShowPasswords {
+			if key, _, _ := strings.Cut(kv, "="); isSecretEnv(key) {
+				kv = key + "=" + redacted
+			}
+		}
+		env = append(env, "-e", kv)
+	}
+	return env
+}
+
+// tmuxSessionID returns the session_id part of the session_id:window_id target
+func tmuxSessionID(target string) string {
+	if target == tmuxTarget {
+		return tmuxSession
+	}
+	id, _, _ := strings.Cut(target, ":")
+	return id
+}
+
+// tmuxOutput runs the tmux command and returns its trimmed output,
+// in the dry-run mode it prints the command that captures the output into the shell var and returns the placeholder
+func tmuxOutput(args ...string) string {
+	if dryRun.Enabled {
+		printTmux("target=$(", args, ")")
+		return tmuxTarget
+	}
+	out, err := exec.Command("tmux", args...).Output()
+	if err != nil {
+		logger.Fatal("tmux ", args[0], " failed: ", tmuxError(err))
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// tmuxRun runs the tmux command, or prints it in the dry-run mode
+func tmuxRun(args ...string) {
+	if dryRun.Enabled {
+		printTmux("", args, "")
+		return
+	}
+	if err := exec.Command("tmux", args...).Run(); err != nil {
+		logger.Fatal("tmux ", args[0], " failed: ", tmuxError(err))
+	}
+}
+
+// tmuxAttach attaches the current terminal to the tmux session
+func tmuxAttach(args ...string) {
+	if dryRun.Enabled {
+		printTmux("", append([]string{"attach-session"}, args...), "")
+		return
+	}
+	cmd := exec.Command("tmux", append([]string{"attach-session"}, args...)...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		logger.Fatal("cannot attach to the tmux session:", err)
+	}
+}
+
+// tmuxError returns the tmux stderr message, if any
+func tmuxError(err error) string {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+		return strings.TrimSpace(string(exitErr.Stderr))
+	}
+	return err.Error()
+}
+
+// printTmux prints the tmux command line with the placeholders replaced by the shell vars
+func printTmux(prefix string, args []string, suffix string) {
+	line := shell.Join(append([]string{"tmux"}, args...))
+	line = strings.NewReplacer(shell.Quote(tmuxTarget), `"$target"`, shell.Quote(tmuxSession), `"${target%%:*}"`).Replace(line)
+	os.Stdout.WriteString(prefix + line + suffix + "\n") //nolint:errcheck // nothing to do with the error here
+}
@@ -0,0 +1,303 @@
+package ssh
+
+import (
+	"fmt"
+	"net"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/etkecc/ansible-ssh/internal/logger"
+	"github.com/etkecc/ansible-ssh/internal/shell"
+	"github.com/etkecc/go-ansible"
+)
+
+// transferTool is a file transfer tool that uses ssh
+type transferTool struct {
+	valueFlags string // short flags that take a value
+	portFlag   string // the port flag, empty if the port is passed within the rsh command (rsync)
+	uri        string // the URI scheme that allows to set the port per operand, empty if not supported
+}
+
+// transferTools are the supported file transfer tools
+var transferTools = map[string]transferTool{
+	"scp":   {valueFlags: "cDFiJloPSX", portFlag: "-P", uri: "scp://"},
+	"sftp":  {valueFlags: "BbcDFiJloPRSsX", portFlag: "-P"},
+	"rsync": {valueFlags: "efBTM@"},
+}
+
+// Resolver returns the inventory host by name, or nil if the host is not found
+type Resolver func(name string) *ansible.Host
+
+// remoteOperand is the [user@]host:path operand that refers to the inventory host
+type remoteOperand struct {
+	idx  int
+	user string
+	path string
+	bare bool // sftp destination without the path, the colon is not added
+	host *ansible.Host
+}
+
+// transferArgs is the parsed command line of the file transfer tool
+type transferArgs struct {
+	operands []int // indexes of the operands
+	port     bool  // port is set by the user
+	keys     bool  // identity files are set by the user
+	rsh      int   // index of the rsync remote shell command (-e value), -1 if not set
+}
+
+// IsTransferTool returns true if the tool is a supported file transfer tool (scp, sftp, rsync)
+func IsTransferTool(tool string) bool {
+	_, ok := transferTools[tool]
+	return ok
+}
+
+// RunTransfer runs the file transfer tool (scp, sftp or rsync) with the [user@]host:path operands rewritten using the inventory:
+// host names are replaced with the addresses, and the ports, keys and ssh options of the hosts are passed to the tool
+func RunTransfer(sshCmd, tool string, raw []string, resolve Resolver, strict bool, environ []string) {
+	argv, host, err := buildTransferArgs(sshCmd, tool, raw, resolve, strict)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	logger.Debug("command:", argv)
+	execute(exec.Command(argv[0], argv[1:]...), host, environ) //nolint:gosec // that's intended
+}
+
+// buildTransferArgs returns the tool command line with the operands rewritten, and the first inventory host.
+// The ports are set per operand if the tool supports URIs (scp), all other ssh options apply to the whole command,
+// so the hosts with different options cannot be used within the same command
+func buildTransferArgs(sshCmd, tool string, raw []string, resolve Resolver, strict bool) ([]string, *ansible.Host, error) {
+	spec := transferTools[tool]
+	parsed := parseTransferArgs(spec, raw)
+	args := slices.Clone(raw)
+
+	remotes := []remoteOperand{}
+	for i, idx := range parsed.operands {
+		user, name, path, ok := splitRemoteOperand(raw[idx], tool == "sftp" && i == 0)
+		if !ok {
+			continue
+		}
+		host := resolve(name)
+		if host == nil {
+			if strict {
+				return nil, nil, fmt.Errorf("host %s not found within inventory", name)
+			}
+			continue
+		}
+		bare := tool == "sftp" && i == 0 && path == ""
+		remotes = append(remotes, remoteOperand{idx: idx, user: user, path: path, bare: bare, host: host})
+	}
+	if len(remotes) == 0 {
+		return append([]string{tool}, args...), nil, nil
+	}
+
+	ports := []int{}
+	for _, remote := range remotes {
+		if !slices.Contains(ports, remote.host.Port) {
+			ports = append(ports, remote.host.Port)
+		}
+	}
+	perOperandPort := len(ports) > 1 && spec.uri != "" && !parsed.port
+	for _, remote := range remotes {
+		args[remote.idx] = remote.rewrite(spec, perOperandPort)
+	}
+
+	first := remotes[0].host
+	sshArgs, executable := hostTransferArgs(first, parsed, !parsed.port && !perOperandPort)
+	for _, remote := range remotes[1:] {
+		hostArgs, hostExecutable := hostTransferArgs(remote.host, parsed, !parsed.port && !perOperandPort)
+		if !slices.Equal(hostArgs, sshArgs) || hostExecutable != executable {
+			return nil, nil, fmt.Errorf("hosts %s and %s have different ssh options (%q and %q), %s cannot apply them per host, transfer the files of each host separately",
+				first.Name, remote.host.Name, strings.TrimSpace(executable+" "+shell.Join(sshArgs)), strings.TrimSpace(hostExecutable+" "+shell.Join(hostArgs)), tool)
+		}
+	}
+
+	if spec.portFlag == "" { // rsync: ssh options go into the remote shell command
+		return append([]string{tool}, withRsh(args, parsed.rsh, sshCmd, executable, sshArgs)...), first, nil
+	}
+	if len(sshArgs) > 1 && sshArgs[0] == "-p" {
+		sshArgs[0] = spec.portFlag
+	}
+	if executable != "" {
+		sshArgs = append(sshArgs, "-S", executable)
+	}
+	// the inventory options go right before the operands, so the user-provided options win (ssh uses the first obtained value)
+	insertAt := parsed.operands[0]
+	args = slices.Insert(args, insertAt, sshArgs...)
+	return append([]string{tool}, args...), first, nil
+}
+
+// hostTransferArgs returns the ssh arguments (port, keys and -o options) and the ssh executable of the host,
+// the port and keys are skipped if set by the user
+func hostTransferArgs(host *ansible.Host, parsed transferArgs, withPort bool) (sshArgs []string, executable string) {
+	sshArgs = []string{}
+	if withPort && host.Port != 0 {
+		sshArgs = append(sshArgs, "-p", strconv.Itoa(host.Port))
+	}
+	keys, options := transferOptions(host)
+	if !parsed.keys {
+		for _, key := range keys {
+			sshArgs = append(sshArgs, "-i", key)
+		}
+	}
+	for _, option := range options {
+		sshArgs = append(sshArgs, "-o", option)
+	}
+	return sshArgs, host.Vars.String("ansible_ssh_executable")
+}
+
+// parseTransferArgs finds the operands and the user-provided port, keys and remote shell
+func parseTransferArgs(spec transferTool, raw []string) transferArgs {
+	parsed := transferArgs{rsh: -1}
+	var terminated bool
+	for i := 0; i < len(raw); i++ {
+		arg := raw[i]
+		switch {
+		case terminated || !isOption(arg):
+			parsed.operands = append(parsed.operands, i)
+		case arg == "--":
+			terminated = true
+		case strings.HasPrefix(arg, "--"):
+			if name, _, withValue := strings.Cut(arg, "="); name == "--rsh" {
+				if !withValue && i+1 < len(raw) {
+					i++
+				}
+				parsed.rsh = i
+			}
+		default:
+			for j := 1; j < len(arg); j++ {
+				flag := arg[j]
+				if !strings.ContainsRune(spec.valueFlags, rune(flag)) {
+					continue
+				}
+				if j+1 == len(arg) && i+1 < len(raw) {
+					i++
+				}
+				switch {
+				case flag == 'e':
+					parsed.rsh = i
+				case flag == 'i':
+					parsed.keys = true
+				case flag == 'P' && spec.portFlag == "-P":
+					parsed.port = true
+				}
+				break
+			}
+		}
+	}
+	return parsed
+}
+
+// splitRemoteOperand splits the [user@]host:path operand (host may be a bracketed IPv6 address),
+// returns false for local paths, URIs and rsync daemon operands. If the colon is optional (sftp destination), the path may be omitted
+func splitRemoteOperand(operand string, optionalColon bool) (user, host, path string, ok bool) {
+	if strings.Contains(operand, "://") || strings.HasPrefix(operand, "/") || strings.HasPrefix(operand, ".") {
+		return "", "", "", false
+	}
+	rest := operand
+	at, colon := strings.Index(operand, "@"), strings.Index(operand, ":")
+	if at != -1 && (colon == -1 || at < colon) && !strings.Contains(operand[:at], "/") {
+		user, rest = operand[:at], operand[at+1:]
+	}
+
+	var after string
+	if strings.HasPrefix(rest, "[") {
+		end := strings.Index(rest, "]")
+		if end == -1 {
+			return "", "", "", false
+		}
+		host, after = rest[1:end], rest[end+1:]
+	} else if idx := strings.Index(rest, ":"); idx != -1 {
+		host, after = rest[:idx], rest[idx:]
+	} else {
+		host = rest
+	}
+	if host == "" || strings.Contains(host, "/") {
+		return "", "", "", false
+	}
+
+	switch {
+	case strings.HasPrefix(after, "::"): // rsync daemon
+		return "", "", "", false
+	case strings.HasPrefix(after, ":"):
+		return user, host, after[1:], true
+	case after == "" && optionalColon:
+		return user, host, "", true
+	default:
+		return "", "", "", false
+	}
+}
+
+// rewrite returns the operand with the host address (and the port, if required)
+func (r remoteOperand) rewrite(spec transferTool, withPort bool) string {
+	user := r.user
+	if user == "" {
+		user = r.host.User
+	}
+	if user != "" {
+		user += "@"
+	}
+	address := r.host.Host
+	if withPort {
+		port := r.host.Port
+		if port == 0 {
+			port = 22
+		}
+		return spec.uri + user + net.JoinHostPort(address, strconv.Itoa(port)) + "/" + r.path
+	}
+	if strings.Contains(address, ":") {
+		address = "[" + address + "]"
+	}
+	if r.bare {
+		return user + address
+	}
+	return user + address + ":" + r.path
+}
+
+// transferOptions returns the private keys and ssh options (as -o values) of the host, port forwardings and tty options are skipped
+func transferOptions(host *ansible.Host) (keys, options []string) {
+	sshArgs := buildArgs(nil, ParseArgs([]string{host.Name}), host)
+	if len(sshArgs) > 0 {
+		sshArgs = sshArgs[:len(sshArgs)-1] // the destination
+	}
+	for _, option := range configOptions(sshArgs) {
+		if strings.HasPrefix(option, "#") {
+			continue
+		}
+		key, value, _ := strings.Cut(option, " ")
+		switch key {
+		case "IdentityFile":
+			keys = append(keys, value)
+		case "Port", "User", "LocalForward", "RemoteForward", "DynamicForward", "RequestTTY": // not relevant for file transfers
+		default:
+			options = append(options, key+"="+value)
+		}
+	}
+	return keys, options
+}
+
+// withRsh sets the rsync remote shell command (-e) with the ssh options, the user-provided command is extended
+func withRsh(args []string, rshIdx int, sshCmd, executable string, sshArgs []string) []string {
+	rsh := sshCmd
+	if executable != "" {
+		rsh = executable
+	}
+	if rshIdx != -1 {
+		rsh = args[rshIdx]
+		if name, value, ok := strings.Cut(rsh, "="); ok && name == "--rsh" {
+			rsh = value
+		}
+	}
+	rsh = strings.TrimSpace(rsh + " " + shell.Join(sshArgs))
+
+	if rshIdx == -1 {
+		return append([]string{"-e", rsh}, args...)
+	}
+	if strings.HasPrefix(args[rshIdx], "--rsh=") {
+		args[rshIdx] = "--rsh=" + rsh
+	} else {
+		args[rshIdx] = rsh
+	}
+	return args
+}
@@ -0,0 +1,153 @@
+package ssh
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/etkecc/go-ansible"
+)
+
+// testResolver returns the resolver of the hosts by name
+func testResolver(hosts ...*ansible.Host) Resolver {
+	return func(name string) *ansible.Host {
+		for _, host := range hosts {
+			if host.Name == name {
+				return host
+			}
+		}
+		return nil
+	}
+}
+
+func TestBuildTransferArgs(t *testing.T) {
+	web01 := &ansible.Host{Name: "web01", Host: "10.0.0.1", Port: 2222, User: "deploy", PrivateKeys: []string{"/keys/web"}}
+	web02 := &ansible.Host{Name: "web02", Host: "10.0.0.2", Port: 2200, User: "deploy", PrivateKeys: []string{"/keys/web"}}
+	web03 := &ansible.Host{Name: "web03", Host: "10.0.0.3", Port: 2222, User: "deploy", PrivateKeys: []string{"/keys/web"}}
+	db01 := &ansible.Host{Name: "db01", Host: "10.0.1.1", Port: 2222, User: "deploy", PrivateKeys: []string{"/keys/db"}}
+	custom := &ansible.Host{Name: "custom", Host: "10.0.2.1", Port: 22, Vars: ansible.HostVars{"ansible_ssh_executable": "/opt/ssh"}}
+	plain := &ansible.Host{Name: "plain", Host: "10.0.2.2", Port: 22}
+	jumped := &ansible.Host{Name: "jumped", Host: "10.0.2.3", Port: 22, Vars: ansible.HostVars{"ansible_ssh_common_args": "-o ProxyJump=bastion"}}
+	resolve := testResolver(web01, web02, web03, db01, custom, plain, jumped)
+
+	tests := []struct {
+		name     string
+		tool     string
+		args     []string
+		expected []string
+		host     string
+		err      bool
+	}{
+		{
+			name:     "local only",
+			tool:     "scp",
+			args:     []string{"a.txt", "b.txt"},
+			expected: []string{"scp", "a.txt", "b.txt"},
+		},
+		{
+			name:     "scp single host",
+			tool:     "scp",
+			args:     []string{"-r", "dir", "web01:/tmp/"},
+			expected: []string{"scp", "-r", "-P", "2222", "-i", "/keys/web", "dir", "deploy@10.0.0.1:/tmp/"},
+			host:     "web01",
+		},
+		{
+			name:     "scp hosts with the same options",
+			tool:     "scp",
+			args:     []string{"web01:/a", "web03:/b"},
+			expected: []string{"scp", "-P", "2222", "-i", "/keys/web", "deploy@10.0.0.1:/a", "deploy@10.0.0.3:/b"},
+			host:     "web01",
+		},
+		{
+			name:     "scp ports per operand",
+			tool:     "scp",
+			args:     []string{"web01:/a", "web02:/b"},
+			expected: []string{"scp", "-i", "/keys/web", "scp://deploy@10.0.0.1:2222//a", "scp://deploy@10.0.0.2:2200//b"},
+			host:     "web01",
+		},
+		{
+			name:     "scp user port",
+			tool:     "scp",
+			args:     []string{"-P", "22", "web01:/a", "web02:/b"},
+			expected: []string{"scp", "-P", "22", "-i", "/keys/web", "deploy@10.0.0.1:/a", "deploy@10.0.0.2:/b"},
+			host:     "web01",
+		},
+		{
+			name: "scp different keys",
+			tool: "scp",
+			args: []string{"web01:/a", "db01:/b"},
+			err:  true,
+		},
+		{
+			name:     "scp user keys",
+			tool:     "scp",
+			args:     []string{"-i", "/keys/mine", "web01:/a", "db01:/b"},
+			expected: []string{"scp", "-i", "/keys/mine", "-P", "2222", "deploy@10.0.0.1:/a", "deploy@10.0.1.1:/b"},
+			host:     "web01",
+		},
+		{
+			name: "scp different executables",
+			tool: "scp",
+			args: []string{"custom:/a", "plain:/b"},
+			err:  true,
+		},
+		{
+			name:     "scp executable",
+			tool:     "scp",
+			args:     []string{"custom:/a", "."},
+			expected: []string{"scp", "-P", "22", "-S", "/opt/ssh", "10.0.2.1:/a", "."},
+			host:     "custom",
+		},
+		{
+			name: "scp different ssh options",
+			tool: "scp",
+			args: []string{"plain:/a", "jumped:/b"},
+			err:  true,
+		},
+		{
+			name:     "rsync executable",
+			tool:     "rsync",
+			args:     []string{"-a", "custom:/a", "."},
+			expected: []string{"rsync", "-e", "/opt/ssh -p 22", "-a", "10.0.2.1:/a", "."},
+			host:     "custom",
+		},
+		{
+			name:     "rsync user rsh",
+			tool:     "rsync",
+			args:     []string{"--rsh=ssh -v", "web01:/a", "."},
+			expected: []string{"rsync", "--rsh=ssh -v -p 2222 -i /keys/web", "deploy@10.0.0.1:/a", "."},
+			host:     "web01",
+		},
+		{
+			name: "not found, strict",
+			tool: "scp",
+			args: []string{"missing:/a", "."},
+			err:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			strict := test.name == "not found, strict"
+			argv, host, err := buildTransferArgs("ssh", test.tool, test.args, resolve, strict)
+			if test.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %q", argv)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(argv, test.expected) {
+				t.Errorf("expected %q, got %q", test.expected, argv)
+			}
+			name := ""
+			if host != nil {
+				name = host.Name
+			}
+			if name != test.host {
+				t.Errorf("expected host %q, got %q", test.host, name)
+			}
+		})
+	}
+}
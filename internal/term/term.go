@@ -0,0 +1,2 @@
+// Package term provides the minimal terminal handling, without the golang.org/x/term dependency
+package term
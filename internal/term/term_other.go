@@ -0,0 +1,41 @@
+//go:build !linux && !darwin && !windows
+
+package term
+
+import (
+	"errors"
+	"os"
+)
+
+// State is the terminal state to be restored
+type State struct{}
+
+// IsTerminal always returns false on the unsupported platforms
+func IsTerminal(_ uintptr) bool {
+	return false
+}
+
+// MakeRaw is not supported on this platform
+func MakeRaw(_ uintptr) (*State, error) {
+	return nil, errors.ErrUnsupported
+}
+
+// Restore is not supported on this platform
+func Restore(_ uintptr, _ *State) error {
+	return errors.ErrUnsupported
+}
+
+// Size is not supported on this platform
+func Size(_ uintptr) (width, height int, err error) {
+	return 0, 0, errors.ErrUnsupported
+}
+
+// OpenPTY is not supported on this platform
+func OpenPTY() (master, slave *os.File, err error) {
+	return nil, nil, errors.ErrUnsupported
+}
+
+// SetSize is not supported on this platform
+func SetSize(_ uintptr, _, _ int) error {
+	return errors.ErrUnsupported
+}
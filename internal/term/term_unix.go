@@ -0,0 +1,100 @@
+//go:build linux || darwin
+
+package term
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// State is the terminal state to be restored
+type State struct {
+	termios syscall.Termios
+}
+
+// IsTerminal returns true if the file descriptor is a terminal
+func IsTerminal(fd uintptr) bool {
+	_, err := getTermios(fd)
+	return err == nil
+}
+
+// MakeRaw puts the terminal into the raw mode (like cfmakeraw), returns the previous state
+func MakeRaw(fd uintptr) (*State, error) {
+	termios, err := getTermios(fd)
+	if err != nil {
+		return nil, err
+	}
+	state := &State{termios: *termios}
+
+	termios.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP | syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+	termios.Oflag &^= syscall.OPOST
+	termios.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	termios.Cflag &^= syscall.CSIZE | syscall.PARENB
+	termios.Cflag |= syscall.CS8
+	termios.Cc[syscall.VMIN] = 1
+	termios.Cc[syscall.VTIME] = 0
+	if err := setTermios(fd, termios); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// Restore restores the terminal state
+func Restore(fd uintptr, state *State) error {
+	if state == nil {
+		return nil
+	}
+	return setTermios(fd, &state.termios)
+}
+
+// Size returns the terminal width and height
+func Size(fd uintptr) (width, height int, err error) {
+	var ws struct {
+		Row, Col, Xpixel, Ypixel uint16
+	}
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, syscall.TIOCGWINSZ, uintptr(unsafe.Pointer(&ws))); errno != 0 { //nolint:gosec // that's intended
+		return 0, 0, errno
+	}
+	return int(ws.Col), int(ws.Row), nil
+}
+
+// OpenPTY opens a new pseudo-terminal pair, the slave is the terminal of the child process
+func OpenPTY() (master, slave *os.File, err error) {
+	master, name, err := openPTY()
+	if err != nil {
+		return nil, nil, err
+	}
+	slave, err = os.OpenFile(name, os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		master.Close()
+		return nil, nil, err
+	}
+	return master, slave, nil
+}
+
+// SetSize sets the terminal width and height, the foreground process of the terminal gets SIGWINCH
+func SetSize(fd uintptr, width, height int) error {
+	ws := struct {
+		Row, Col, Xpixel, Ypixel uint16
+	}{Row: uint16(height), Col: uint16(width)} //nolint:gosec // terminal sizes fit
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, syscall.TIOCSWINSZ, uintptr(unsafe.Pointer(&ws))); errno != 0 { //nolint:gosec // that's intended
+		return errno
+	}
+	return nil
+}
+
+func getTermios(fd uintptr) (*syscall.Termios, error) {
+	var termios syscall.Termios
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, ioctlGetTermios, uintptr(unsafe.Pointer(&termios))); errno != 0 { //nolint:gosec // that's intended
+		return nil, errno
+	}
+	return &termios, nil
+}
+
+func setTermios(fd uintptr, termios *syscall.Termios) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, ioctlSetTermios, uintptr(unsafe.Pointer(termios))); errno != 0 { //nolint:gosec // that's intended
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package term
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// State is the terminal state to be restored
+type State struct{}
+
+// IsTerminal returns true if the file descriptor is a console
+func IsTerminal(fd uintptr) bool {
+	var mode uint32
+	return syscall.GetConsoleMode(syscall.Handle(fd), &mode) == nil
+}
+
+// MakeRaw is not supported on windows yet
+func MakeRaw(_ uintptr) (*State, error) {
+	return nil, errors.ErrUnsupported
+}
+
+// Restore is not supported on windows yet
+func Restore(_ uintptr, _ *State) error {
+	return errors.ErrUnsupported
+}
+
+// Size is not supported on windows yet
+func Size(_ uintptr) (width, height int, err error) {
+	return 0, 0, errors.ErrUnsupported
+}
+
+// OpenPTY is not supported on windows yet
+func OpenPTY() (master, slave *os.File, err error) {
+	return nil, nil, errors.ErrUnsupported
+}
+
+// SetSize is not supported on windows yet
+func SetSize(_ uintptr, _, _ int) error {
+	return errors.ErrUnsupported
+}